@@ -0,0 +1,240 @@
+package dbi
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//ErrPositionalArgs is returned when positional arguments are used without PositionalArgs option
+var ErrPositionalArgs = errors.New("Positional arguments are not enabled, use PositionalArgs option")
+
+//ErrMixedArgs is returned when a query uses both named and positional arguments
+var ErrMixedArgs = errors.New("Named and positional arguments can not be mixed in one query")
+
+//ErrUpdateWherePositional is returned when UpdateWhere is called with positional arguments,
+//their placeholders would not line up with the ones generated for the SET clause
+var ErrUpdateWherePositional = errors.New("UpdateWhere does not support positional arguments")
+
+//ErrEmptySlice is returned when a named argument is an empty slice which would expand to no placeholders,
+//IN () is not valid SQL and neither IN (NULL) nor NOT IN (NULL) do what one would expect
+var ErrEmptySlice = errors.New("Empty slice can not be expanded into placeholders")
+
+//Positional returns the values as unnamed arguments to be used with PositionalArgs option
+//e.g. db.Select(&persons, nil, "WHERE last = ?", Positional("Moe")...)
+func Positional(vals ...interface{}) []sql.NamedArg {
+	args := make([]sql.NamedArg, 0, len(vals))
+	for _, v := range vals {
+		args = append(args, sql.NamedArg{Value: v})
+	}
+	return args
+}
+
+//positionalArgs returns the values of unnamed arguments
+func positionalArgs(args []sql.NamedArg) []interface{} {
+	var vals []interface{}
+	for _, v := range args {
+		if v.Name == "" {
+			vals = append(vals, v.Value)
+		}
+	}
+	return vals
+}
+
+//arrayArg wraps a slice value which should not be expanded
+type arrayArg struct {
+	v interface{}
+}
+
+//Array marks a slice named argument to be passed to the driver as a single value
+//instead of being expanded into a list of placeholders.
+//This is useful for Postgres arrays e.g. "WHERE id = ANY(@ids)" with db.Named("ids", dbi.Array(ids)).
+//Slices of named arguments used to be passed to the driver as they are,
+//code relying on driver support for slices (e.g. pgx) has to wrap them in Array.
+func Array(v interface{}) interface{} {
+	return arrayArg{v}
+}
+
+//expandable reports whether v is a slice or an array that should be expanded into several placeholders
+//[]byte, byte arrays (e.g. [16]byte UUID) and values implementing driver.Valuer are passed as they are
+func expandable(v interface{}) (reflect.Value, bool) {
+	if v == nil {
+		return reflect.Value{}, false
+	}
+	if _, ok := v.(driver.Valuer); ok {
+		return reflect.Value{}, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv, false
+		}
+		return rv, true
+	default:
+		return rv, false
+	}
+}
+
+//argArity returns the number of placeholders a value expands to
+func argArity(v interface{}) int {
+	if rv, ok := expandable(v); ok {
+		return rv.Len()
+	}
+	return 1
+}
+
+//argLookup returns the value of a named argument
+type argLookup func(name string) (interface{}, bool)
+
+//translateQuery turns query with named arguments into query with placeholders
+//and returns the values to be passed along in the order of the placeholders
+//together with the names of the arguments they came from (empty for positional arguments).
+//Values are looked up in args first and then in source (if not nil) as configured via WithArgs.
+func translateQuery(
+	syntax argSyntax,
+	phFunc placeHolderFunc,
+	query string,
+	args []sql.NamedArg,
+	source argLookup) (string, []interface{}, []string, error) {
+	positional := positionalArgs(args)
+	if len(positional) > 0 && !syntax.positional {
+		return "", nil, nil, ErrPositionalArgs
+	}
+	kmap := make(map[string]interface{})
+	for _, v := range args {
+		kmap[v.Name] = v.Value
+	}
+	lookup := func(name string) (interface{}, bool) {
+		if v, ok := kmap[name]; ok {
+			return v, true
+		}
+		if source != nil {
+			return source(name)
+		}
+		return nil, false
+	}
+	arity := func(name string) int {
+		v, _ := lookup(name)
+		return argArity(v)
+	}
+	query, keywords, err := produceExpandedQuery(syntax, phFunc, query, arity)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if len(positional) > 0 {
+		if len(keywords) > 0 {
+			return "", nil, nil, ErrMixedArgs
+		}
+		return query, positional, make([]string, len(positional)), nil
+	}
+	qargs, names, err := mapNamedArgsToValues(keywords, lookup)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return query, qargs, names, nil
+}
+
+func mapNamedArgsToValues(keywords []string, lookup argLookup) ([]interface{}, []string, error) {
+	qargs := make([]interface{}, 0, len(keywords))
+	names := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		v, ok := lookup(keyword)
+		if !ok {
+			return qargs, names, fmt.Errorf(
+				"No such named keyword argument: %s", keyword)
+		}
+		if a, isArray := v.(arrayArg); isArray {
+			qargs = append(qargs, a.v)
+			names = append(names, keyword)
+			continue
+		}
+		if rv, ok := expandable(v); ok {
+			if rv.Len() == 0 {
+				return qargs, names, fmt.Errorf("%w: %s", ErrEmptySlice, keyword)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				qargs = append(qargs, rv.Index(i).Interface())
+				names = append(names, keyword)
+			}
+			continue
+		}
+		qargs = append(qargs, v)
+		names = append(names, keyword)
+	}
+	return qargs, names, nil
+}
+
+//newArgLookup returns argLookup for a map with string keys, DBRowMarshaler or a struct.
+//DBRowMarshaler provides its DBRow() column names,
+//struct provides its exported field names or the name in dbi tag e.g. `dbi:"last"`, `dbi:"-"` skips the field.
+func newArgLookup(src interface{}) (argLookup, error) {
+	if m, ok := src.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	}
+	if rm, ok := src.(DBRowMarshaler); ok {
+		values := make(map[string]interface{})
+		for _, c := range rm.DBRow() {
+			values[c.Name] = c.Val
+		}
+		return newArgLookup(values)
+	}
+	rv := reflect.ValueOf(src)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	switch {
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		return func(name string) (interface{}, bool) {
+			v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+			return v.Interface(), true
+		}, nil
+	case rv.Kind() == reflect.Struct:
+		values := make(map[string]interface{})
+		structArgs(rv, values)
+		return newArgLookup(values)
+	default:
+		return nil, fmt.Errorf("Expected a struct, map or DBRowMarshaler as source of named arguments but got %T", src)
+	}
+}
+
+//structArgs collects exported fields of struct rv into values, embedded structs are flattened
+func structArgs(rv reflect.Value, values map[string]interface{}) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		tag := f.Tag.Get("dbi")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			fv := rv.Field(i)
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				structArgs(fv, values)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			//unexported
+			continue
+		}
+		name := f.Name
+		if tag != "" {
+			name = tag
+		}
+		if _, exists := values[name]; !exists {
+			values[name] = rv.Field(i).Interface()
+		}
+	}
+}
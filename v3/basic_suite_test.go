@@ -1,10 +1,13 @@
 package dbi
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 	"time"
 )
@@ -279,7 +282,7 @@ func (s *BasicSuite) Test5PersonDemo(t *testing.T, db *H) {
 
 	qc := StmtContext{}
 	initStmContext(&qc, nil)
-	newpk, err := lastInsertPKID(tx.tx, &qc, tx.dbi.placeholder, tx.dbi.lw, p1, BustedResult{})
+	newpk, err := lastInsertPKID(tx.tx, &qc, tx.dbi.placeholder, tx.dbi.queryLogger(), p1, BustedResult{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -554,3 +557,1251 @@ func (s *BasicSuite) Test8PersonDemoInTransaction(t *testing.T, db *H) {
 	}
 
 }
+
+func (s *BasicSuite) Test9Validation(t *testing.T, db *H) {
+	sub := &Subscriber{Email: "john@example.com", Name: "John", Age: 30}
+	db.DropTable(sub, nil)
+	if err := db.CreateTable(sub, nil); err != nil {
+		t.Fatal(err)
+	}
+	pk, err := db.Insert(sub, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.ID = pk.Val.(int64)
+	sub.Name = "Johnathan Doe"
+	sub.Email = "john"
+	err = db.Update(sub, nil)
+	ve, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("want *ValidationError got %v", err)
+	}
+	if len(ve.Fields) != 2 {
+		t.Fatalf("want 2 failed columns got %s", ve)
+	}
+	_, err = db.Insert(&Subscriber{Age: -1}, nil)
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("want *ValidationError got %v", err)
+	}
+	var results []Subscriber
+	if err := db.Select(&results, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Name != "John" {
+		t.Fatalf("invalid models must not be written, got %v", results)
+	}
+}
+
+func (s *BasicSuite) Test10UpdateDeleteWhere(t *testing.T, db *H) {
+	p := &Person{}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]string{{"John", "Doe"}, {"Jane", "Doe"}, {"John", "Milton"}} {
+		p.FirstName = v[0]
+		p.LastName = v[1]
+		if _, err := db.Insert(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := db.UpdateWhere(
+		p,
+		nil,
+		[]Col{NewCol("last", "Moe", nil)},
+		"WHERE last = @last",
+		sql.Named("last", "Doe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 updated rows got %d", n)
+	}
+	if _, err := db.UpdateWhere(p, nil, nil, ""); err != ErrNoColumns {
+		t.Fatalf("want %v got %v", ErrNoColumns, err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = tx.DeleteWhere(p, nil, "WHERE first = @first AND last = @last",
+		sql.Named("first", "John"),
+		sql.Named("last", "Moe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 deleted row got %d", n)
+	}
+	n, err = tx.UpdateWhere(p, nil, []Col{NewCol("first", "J.", nil)}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 updated rows got %d", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var results []Person
+	if err := db.Select(&results, nil, "WHERE first = @first", sql.Named("first", "J.")); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want 2 results got %d", len(results))
+	}
+	n, err = db.DeleteWhere(p, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 deleted rows got %d", n)
+	}
+}
+
+func (s *BasicSuite) Test11ExecQueryRaw(t *testing.T, db *H) {
+	p := &Person{FirstName: "John", LastName: "Doe"}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	res, err := db.Exec(nil, "UPDATE person SET last = @new WHERE last = @old",
+		sql.Named("old", "Doe"),
+		sql.Named("new", "Moe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("want 1 affected row got %d %v", n, err)
+	}
+	if _, err := db.Exec(nil, "DELETE FROM person WHERE id = @id"); err == nil {
+		t.Fatal("expected error for missing named argument")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(nil, "UPDATE person SET first = @first", sql.Named("first", "Jim")); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := tx.QueryRaw(nil, "SELECT first, last FROM person WHERE last = @last", sql.Named("last", "Moe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var count int
+	for rows.Next() {
+		var first, last string
+		if err := rows.Scan(&first, &last); err != nil {
+			t.Fatal(err)
+		}
+		if first != "Jim" || last != "Moe" {
+			t.Fatalf("want Jim Moe got %s %s", first, last)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("want 1 row got %d", count)
+	}
+}
+
+func (s *BasicSuite) Test12SelectSQL(t *testing.T, db *H) {
+	cp := &Company{}
+	db.DropTable(cp, nil)
+	if err := db.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	ar := &AnnualReport{}
+	db.DropTable(ar, nil)
+	if err := db.CreateTable(ar, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]string{{"Intel", "INTC"}, {"IBM", "IBM"}} {
+		cp.Name = v[0]
+		cp.Ticker = v[1]
+		pk, err := db.Insert(cp, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ar.CompanyID = pk.Val.(int64)
+		ar.Year = 2015
+		if _, err := db.Insert(ar, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var results []Company
+	err := db.SelectSQL(&results, nil, `SELECT c.ID, c.Name, c.Ticker
+FROM company c JOIN annual_report ar ON ar.company_id = c.ID
+WHERE ar.year = @year AND c.Ticker != @ticker`,
+		sql.Named("year", 2015),
+		sql.Named("ticker", "INTC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Ticker != "IBM" {
+		t.Fatalf("want IBM got %v", results)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var mismatch []*Company
+	err = tx.SelectSQL(&mismatch, nil, "SELECT ID, Name FROM company")
+	if err == nil || !strings.Contains(err.Error(), "Ticker") {
+		t.Fatalf("want column mismatch error listing Ticker got %v", err)
+	}
+}
+
+func (s *BasicSuite) Test13SelectOneCountExists(t *testing.T, db *H) {
+	p := &Person{}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]string{{"John", "Doe"}, {"Jane", "Doe"}, {"John", "Milton"}} {
+		p.FirstName = v[0]
+		p.LastName = v[1]
+		if _, err := db.Insert(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var one Person
+	if err := db.SelectOne(&one, nil, "WHERE last = @last", sql.Named("last", "Milton")); err != nil {
+		t.Fatal(err)
+	}
+	if one.FirstName != "John" {
+		t.Fatalf("want John got %s", one.FirstName)
+	}
+	if err := db.SelectOne(&one, nil, "WHERE last = @last", sql.Named("last", "Doe")); err != ErrMultipleRows {
+		t.Fatalf("want %v got %v", ErrMultipleRows, err)
+	}
+	n, err := db.Count(p, nil, "WHERE last = @last", sql.Named("last", "Doe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 got %d", n)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := tx.SelectOne(&one, nil, "WHERE last = @last", sql.Named("last", "Moe")); err != ErrNotFound {
+		t.Fatalf("want %v got %v", ErrNotFound, err)
+	}
+	if n, err := tx.Count(p, nil, ""); err != nil || n != 3 {
+		t.Fatalf("want 3 got %d %v", n, err)
+	}
+	ok, err := tx.Exists(p, nil, "WHERE first = @first", sql.Named("first", "Jane"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Jane should exist")
+	}
+	ok, err = db.Exists(p, nil, "WHERE first = @first", sql.Named("first", "Steve"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("Steve should not exist")
+	}
+}
+
+func (s *BasicSuite) Test14SelectIn(t *testing.T, db *H) {
+	p := &Person{}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, v := range []string{"Doe", "Moe", "Milton", "Blank"} {
+		p.FirstName = "John"
+		p.LastName = v
+		pk, err := db.Insert(p, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, pk.Val.(int))
+	}
+	var results []Person
+	err := db.Select(&results, nil, "WHERE id IN (@ids) AND first = @first ORDER BY id",
+		sql.Named("ids", ids[1:3]),
+		sql.Named("first", "John"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].LastName != "Moe" || results[1].LastName != "Milton" {
+		t.Fatalf("want Moe and Milton got %v", results)
+	}
+	results = nil
+	if err := db.Select(&results, nil, "WHERE id IN (@ids)", sql.Named("ids", []int{})); !errors.Is(err, ErrEmptySlice) {
+		t.Fatalf("want ErrEmptySlice got %v", err)
+	}
+	n, err := db.DeleteWhere(p, nil, "WHERE last IN (@last)", sql.Named("last", []string{"Doe", "Blank"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 deleted got %d", n)
+	}
+}
+
+func (s *BasicSuite) Test15SelectWithArgs(t *testing.T, db *H) {
+	p := &Person{}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]string{{"John", "Doe"}, {"Jane", "Doe"}, {"John", "Milton"}} {
+		p.FirstName = v[0]
+		p.LastName = v[1]
+		if _, err := db.Insert(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filter := struct {
+		First string `dbi:"first"`
+		Last  string
+	}{"John", "Doe"}
+	var results []Person
+	if err := db.Select(&results, WithArgs(filter), "WHERE first = @first AND last = @Last"); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("want 1 result got %d", len(results))
+	}
+	n, err := db.Count(p, WithArgs(map[string]interface{}{"last": "Doe"}), "WHERE last = @last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 got %d", n)
+	}
+	//the model itself as the source
+	up := results[0]
+	up.FirstName = "Jim"
+	n, err = db.UpdateWhere(p, WithArgs(&up), []Col{NewCol("first", up.FirstName, nil)}, "WHERE id = @id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("want 1 got %d", n)
+	}
+}
+
+func (s *BasicSuite) Test16PrefixAndPositional(t *testing.T, db *H) {
+	p := &Person{FirstName: "John", LastName: "Doe"}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	var results []Person
+	err := db.Select(&results, nil, "WHERE last = "+db.placeholder()(), Positional("Doe")...)
+	if err != ErrPositionalArgs {
+		t.Fatalf("want %v got %v", ErrPositionalArgs, err)
+	}
+	colon := newHandle(t, db,
+		NamedArgPrefix(':'),
+		PositionalArgs(),
+	)
+	if err := colon.Select(&results, nil, "WHERE last = :last AND first != '@x'", sql.Named("last", "Doe")); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("want 1 result got %d", len(results))
+	}
+	results = nil
+	if err := colon.Select(&results, nil, "WHERE last = "+db.placeholder()(), Positional("Doe")...); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("want 1 result got %d", len(results))
+	}
+	err = colon.Select(&results, nil, "WHERE last = :last AND first = "+db.placeholder()(),
+		append(Positional("John"), sql.Named("last", "Doe"))...)
+	if err != ErrMixedArgs {
+		t.Fatalf("want %v got %v", ErrMixedArgs, err)
+	}
+	_, err = colon.UpdateWhere(&Person{}, nil, []Col{{Name: "first", Val: "Jim"}}, "WHERE last = "+db.placeholder()(), Positional("Doe")...)
+	if !errors.Is(err, ErrUpdateWherePositional) {
+		t.Fatalf("want %v got %v", ErrUpdateWherePositional, err)
+	}
+	if err := NamedArgPrefix('a')(colon); err == nil {
+		t.Fatal("expected error for letter used as prefix")
+	}
+}
+
+func (s *BasicSuite) Test17SelectPage(t *testing.T, db *H) {
+	p := &Person{}
+	db.DropTable(p, nil)
+	if err := db.CreateTable(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	//two people share the last name so primary key breaks the tie
+	for _, v := range [][]string{{"A", "Doe"}, {"B", "Moe"}, {"C", "Doe"}, {"D", "Blank"}, {"E", "Zed"}, {"F", "Milton"}, {"G", "Moe"}} {
+		p.FirstName = v[0]
+		p.LastName = v[1]
+		if _, err := db.Insert(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firsts := func(persons []Person) string {
+		var buf bytes.Buffer
+		for _, v := range persons {
+			buf.WriteString(v.FirstName)
+		}
+		return buf.String()
+	}
+	var tests = []struct {
+		sort  []Sort
+		pages []string
+	}{
+		{[]Sort{Asc("last")}, []string{"DAC", "FBG", "E"}},
+		{[]Sort{Desc("last")}, []string{"EGB", "FCA", "D"}},
+		{[]Sort{Asc("last"), Desc("first")}, []string{"DCA", "FGB", "E"}},
+	}
+	for _, test := range tests {
+		var (
+			cursor  string
+			cursors []string
+		)
+		//forward
+		for i, want := range test.pages {
+			var results []Person
+			page, err := db.SelectPage(&results, nil, test.sort, 3, cursor, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := firsts(results); got != want {
+				t.Fatalf("%v page %d want %s got %s", test.sort, i, want, got)
+			}
+			if (page.Next == "") != (i == len(test.pages)-1) {
+				t.Fatalf("%v page %d unexpected next cursor %q", test.sort, i, page.Next)
+			}
+			cursors = append(cursors, page.Prev)
+			cursor = page.Next
+		}
+		//and back from the last page
+		cursor = cursors[len(cursors)-1]
+		for i := len(test.pages) - 2; i >= 0; i-- {
+			var results []*Person
+			tx, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, err := tx.SelectPage(&results, nil, test.sort, 3, cursor, "")
+			tx.Rollback()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			for _, v := range results {
+				got.WriteString(v.FirstName)
+			}
+			if got.String() != test.pages[i] {
+				t.Fatalf("%v back page %d want %s got %s", test.sort, i, test.pages[i], got.String())
+			}
+			if (page.Prev == "") != (i == 0) {
+				t.Fatalf("%v back page %d unexpected prev cursor %q", test.sort, i, page.Prev)
+			}
+			cursor = page.Prev
+		}
+	}
+	var results []Person
+	page, err := db.SelectPage(&results, nil, []Sort{Asc("first")}, 2, "", "last = @last", sql.Named("last", "Moe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firsts(results) != "BG" || page.Next != "" || page.Prev != "" {
+		t.Fatalf("want single page BG got %s %v", firsts(results), page)
+	}
+	if _, err := db.SelectPage(&results, nil, []Sort{Asc("first")}, 2, "garbage", ""); err != ErrInvalidCursor {
+		t.Fatalf("want %v got %v", ErrInvalidCursor, err)
+	}
+	if _, err := db.SelectPage(&results, nil, []Sort{Asc("nope")}, 2, "", ""); !errors.Is(err, ErrUnknownSortColumn) {
+		t.Fatalf("want %v got %v", ErrUnknownSortColumn, err)
+	}
+	if _, err := db.SelectPage(&results, nil, nil, 2, "", ""); !errors.Is(err, ErrNoSort) {
+		t.Fatalf("want %v got %v", ErrNoSort, err)
+	}
+	if _, err := db.SelectPage(&results, nil, []Sort{Asc("first")}, 0, "", ""); !errors.Is(err, ErrInvalidPageSize) {
+		t.Fatalf("want %v got %v", ErrInvalidPageSize, err)
+	}
+	//cursor of a different sort is rejected instead of returning a wrong page
+	results = nil
+	page, err = db.SelectPage(&results, nil, []Sort{Asc("last")}, 3, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SelectPage(&results, nil, []Sort{Desc("last")}, 3, page.Next, ""); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("want %v got %v", ErrInvalidCursor, err)
+	}
+	//positional filter arguments are followed by the cursor values
+	positional := newHandle(t, db, PositionalArgs())
+	results = nil
+	page, err = positional.SelectPage(&results, nil, []Sort{Asc("first")}, 1, "", "last = "+db.placeholder()(), Positional("Moe")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results = nil
+	if _, err := positional.SelectPage(&results, nil, []Sort{Asc("first")}, 1, page.Next, "last = "+db.placeholder()(), Positional("Moe")...); err != nil {
+		t.Fatal(err)
+	}
+	if firsts(results) != "G" {
+		t.Fatalf("want second page G got %s", firsts(results))
+	}
+}
+
+func (s *BasicSuite) Test18Preload(t *testing.T, db *H) {
+	cp := &Company{}
+	db.DropTable(cp, nil)
+	if err := db.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	ar := &AnnualReport{}
+	db.DropTable(ar, nil)
+	if err := db.CreateTable(ar, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range [][]string{{"Intel", "INTC"}, {"IBM", "IBM"}, {"Google", "GOOG"}} {
+		cp.Name = v[0]
+		cp.Ticker = v[1]
+		pk, err := db.Insert(cp, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//Intel gets no reports, IBM one and Google two
+		for year := 0; year < i; year++ {
+			ar.CompanyID = pk.Val.(int64)
+			ar.Year = 2015 + year
+			ar.Sales = big.NewInt(int64(year))
+			if _, err := db.Insert(ar, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var companies []Company
+	if err := db.Select(&companies, Preload("reports"), "ORDER BY ID"); err != nil {
+		t.Fatal(err)
+	}
+	if len(companies) != 3 {
+		t.Fatalf("want 3 companies got %d", len(companies))
+	}
+	for i, c := range companies {
+		if len(c.Reports) != i {
+			t.Fatalf("%s want %d reports got %d", c.Name, i, len(c.Reports))
+		}
+		for _, r := range c.Reports {
+			if r.CompanyID != c.ID {
+				t.Fatalf("report of %d attached to %d", r.CompanyID, c.ID)
+			}
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var reports []*AnnualReport
+	if err := tx.Select(&reports, Preload("company"), "ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("want 3 reports got %d", len(reports))
+	}
+	for _, r := range reports {
+		if r.Company == nil || r.Company.ID != r.CompanyID {
+			t.Fatalf("report of %d has wrong company %v", r.CompanyID, r.Company)
+		}
+	}
+	if err := tx.Select(&reports, Preload("nope"), ""); err == nil {
+		t.Fatal("expected error for unknown relation")
+	}
+	google := &Company{}
+	if err := tx.SelectOne(google, Preload("reports"), "WHERE name = @name", sql.Named("name", "Google")); err != nil {
+		t.Fatal(err)
+	}
+	if len(google.Reports) != 2 {
+		t.Fatalf("want 2 reports of Google got %d", len(google.Reports))
+	}
+	companies = nil
+	if err := db.SelectSQL(&companies, Preload("reports"), "SELECT id, name, ticker FROM company WHERE ticker = 'IBM'"); err != nil {
+		t.Fatal(err)
+	}
+	if len(companies) != 1 || len(companies[0].Reports) != 1 {
+		t.Fatalf("want IBM with 1 report got %v", companies)
+	}
+	companies = nil
+	page, err := tx.SelectPage(&companies, Preload("reports"), []Sort{Desc("Name")}, 2, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(companies) != 2 || companies[0].Name != "Intel" || len(companies[0].Reports) != 0 || len(companies[1].Reports) != 1 || page.Next == "" {
+		t.Fatalf("want Intel and IBM with their reports got %v", companies)
+	}
+	if err := db.Get(google, Preload("reports")); !errors.Is(err, ErrPreloadUnsupported) {
+		t.Fatalf("want %v got %v", ErrPreloadUnsupported, err)
+	}
+	if _, err := tx.Count(cp, Preload("reports"), ""); !errors.Is(err, ErrPreloadUnsupported) {
+		t.Fatalf("want %v got %v", ErrPreloadUnsupported, err)
+	}
+}
+
+func (s *BasicSuite) Test19SelectJoin(t *testing.T, db *H) {
+	cp := &Company{}
+	db.DropTable(cp, nil)
+	if err := db.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	ar := &AnnualReport{}
+	db.DropTable(ar, nil)
+	if err := db.CreateTable(ar, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]string{{"Intel", "INTC"}, {"IBM", "IBM"}} {
+		cp.Name = v[0]
+		cp.Ticker = v[1]
+		pk, err := db.Insert(cp, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, year := range []int{2015, 2016} {
+			ar.CompanyID = pk.Val.(int64)
+			ar.Year = year
+			ar.Sales = big.NewInt(int64(year))
+			ar.NetIncome = big.NewInt(int64(year) * 2)
+			if _, err := db.Insert(ar, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	var results []Tuple
+	err := db.SelectJoin(&results, nil,
+		[]DBRowUnmarshaler{&Company{}, &AnnualReport{}},
+		`FROM company JOIN annual_report ON annual_report.company_id = company.ID
+WHERE annual_report.year = @year ORDER BY company.ID`,
+		sql.Named("year", 2016))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want 2 results got %d", len(results))
+	}
+	for _, tuple := range results {
+		c := tuple[0].(*Company)
+		r := tuple[1].(*AnnualReport)
+		if r.CompanyID != c.ID || r.Year != 2016 {
+			t.Fatalf("mismatched tuple %v %v", c, r)
+		}
+		if r.Sales.Int64() != 2016 || r.NetIncome.Int64() != 4032 {
+			t.Fatalf("post processing of DBScan lost values %v %v", r.Sales, r.NetIncome)
+		}
+	}
+	if results[0][0].(*Company).Ticker != "INTC" || results[1][0].(*Company).Ticker != "IBM" {
+		t.Fatal("unexpected order of companies")
+	}
+	//DBScan may scan in several steps and is only called with values of actual rows
+	results = nil
+	err = db.SelectJoin(&results, nil,
+		[]DBRowUnmarshaler{&stepCompany{}, &AnnualReport{}},
+		"FROM company JOIN annual_report ON annual_report.company_id = company.ID WHERE annual_report.year = @year",
+		sql.Named("year", 2016))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0][0].(*stepCompany).Name == "" {
+		t.Fatalf("unexpected results %v", results)
+	}
+	//join the same table twice using aliases
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	results = nil
+	err = tx.SelectJoin(&results, nil,
+		[]DBRowUnmarshaler{JoinAs(&AnnualReport{}, "a"), JoinAs(&AnnualReport{}, "b")},
+		"FROM annual_report a JOIN annual_report b ON a.company_id = b.company_id AND a.year < b.year")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("want 2 results got %d", len(results))
+	}
+	for _, tuple := range results {
+		if tuple[0].(*AnnualReport).Year != 2015 || tuple[1].(*AnnualReport).Year != 2016 {
+			t.Fatalf("unexpected years in %v", tuple)
+		}
+	}
+	if err := tx.SelectJoin(&results, nil, nil, "FROM company"); err != ErrNoPrototypes {
+		t.Fatalf("want %v got %v", ErrNoPrototypes, err)
+	}
+}
+
+func (s *BasicSuite) Test20SelectValues(t *testing.T, db *H) {
+	ar := &AnnualReport{}
+	db.DropTable(ar, nil)
+	if err := db.CreateTable(ar, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range [][]int{{1, 2015}, {2, 2015}, {1, 2016}, {2, 2016}, {3, 2016}} {
+		ar.CompanyID = int64(v[0])
+		ar.Year = v[1]
+		if _, err := db.Insert(ar, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	query := "SELECT year, COUNT(*) AS report_count FROM annual_report WHERE year >= @year GROUP BY year ORDER BY year"
+	var stats []struct {
+		Year        int
+		ReportCount int64
+	}
+	if err := db.SelectValues(&stats, nil, query, sql.Named("year", 2015)); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Year != 2015 || stats[0].ReportCount != 2 || stats[1].ReportCount != 3 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+	var tagged []*struct {
+		Y int   `dbi:"year"`
+		N int64 `dbi:"report_count"`
+	}
+	if err := db.SelectValues(&tagged, nil, query, sql.Named("year", 2016)); err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 1 || tagged[0].Y != 2016 || tagged[0].N != 3 {
+		t.Fatalf("unexpected stats %v", tagged)
+	}
+	var years []int
+	if err := db.SelectValues(&years, nil, "SELECT DISTINCT year FROM annual_report ORDER BY year"); err != nil {
+		t.Fatal(err)
+	}
+	if len(years) != 2 || years[0] != 2015 || years[1] != 2016 {
+		t.Fatalf("unexpected years %v", years)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var maps []map[string]interface{}
+	if err := tx.SelectValues(&maps, nil, query, sql.Named("year", 2015)); err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 2 || fmt.Sprint(maps[1]["report_count"]) != "3" {
+		t.Fatalf("unexpected maps %v", maps)
+	}
+	if err := tx.SelectValues(&years, nil, query, sql.Named("year", 2015)); err == nil {
+		t.Fatal("expected error scanning two columns into []int")
+	}
+	var missing []struct{ Year int }
+	if err := tx.SelectValues(&missing, nil, query, sql.Named("year", 2015)); err == nil {
+		t.Fatal("expected error for column without matching field")
+	}
+}
+
+func (s *BasicSuite) Test21StmtCache(t *testing.T, db *H) {
+	cached := newHandle(t, db, StmtCache(2))
+	defer cached.stmts.close()
+	cp := &Company{}
+	cached.DropTable(cp, nil)
+	if err := cached.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"IBM", "Apple", "Oracle"} {
+		cp.ID = int64(i + 1)
+		cp.Name = name
+		cp.Ticker = name
+		if _, err := cached.Insert(cp, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i <= 3; i++ {
+		c := Company{ID: int64(i)}
+		if err := cached.Get(&c, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := cached.StmtCacheStats()
+	if stats.Hits < 4 || stats.Misses != 2 || stats.Size != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	tx, err := cached.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var companies []Company
+	if err := tx.Select(&companies, nil, "ORDER BY ID"); err != nil {
+		t.Fatal(err)
+	}
+	if len(companies) != 3 || companies[2].Name != "Oracle" {
+		t.Fatalf("unexpected companies %v", companies)
+	}
+	c := Company{ID: 2, Name: "Apple Inc.", Ticker: "AAPL"}
+	if err := tx.Update(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Update(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	c = Company{ID: 2}
+	if err := cached.Get(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Ticker != "AAPL" {
+		t.Fatalf("unexpected company %v", c)
+	}
+	//statements of transactions are prepared on the transaction, a third one outside evicts
+	if err := cached.Update(&c, nil); err != nil {
+		t.Fatal(err)
+	}
+	stats = cached.StmtCacheStats()
+	if stats.Evictions == 0 || stats.Size != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if (&H{}).StmtCacheStats() != (StmtCacheStats{}) {
+		t.Fatal("expected zero stats without cache")
+	}
+}
+
+func (s *BasicSuite) Test22QueryLog(t *testing.T, db *H) {
+	var events []QueryEvent
+	logged := newHandle(t, db, QueryLog(QueryLoggerFunc(func(e *QueryEvent) {
+		events = append(events, *e)
+	})))
+	cp := &Company{}
+	logged.DropTable(cp, nil)
+	if err := logged.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := logged.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.ID = 1
+	cp.Name = "IBM"
+	if _, err := tx.Insert(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var companies []Company
+	if err := logged.Select(&companies, nil, "WHERE Name = @name", sql.Named("name", "IBM")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := logged.Exec(nil, "UPDATE nonexistent SET x = 1"); err == nil {
+		t.Fatal("expected error updating nonexistent table")
+	}
+	var ops []string
+	for _, e := range events {
+		ops = append(ops, e.Operation)
+	}
+	if ops[0] != "DropTable" || ops[1] != "CreateTable" || ops[2] != "Begin" || ops[3] != "Insert" {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+	last := events[len(events)-1]
+	sel := events[len(events)-2]
+	commit := events[len(events)-3]
+	if commit.Operation != "Commit" || commit.TxID == 0 || commit.TxID != tx.ID() || events[3].TxID != tx.ID() {
+		t.Fatalf("unexpected transaction events %v", events)
+	}
+	if events[3].Table != "company" || events[3].RowsAffected != 1 || events[3].Context == nil {
+		t.Fatalf("unexpected insert event %+v", events[3])
+	}
+	if sel.Operation != "Select" || sel.TxID != 0 || len(sel.Args) != 1 || sel.Args[0] != "IBM" || sel.Duration <= 0 {
+		t.Fatalf("unexpected select event %+v", sel)
+	}
+	if last.Operation != "Exec" || last.Err == nil || last.Table != "" {
+		t.Fatalf("unexpected exec event %+v", last)
+	}
+	if err := QueryLog(nil)(logged); err == nil {
+		t.Fatal("expected error for nil query logger")
+	}
+}
+
+func (s *BasicSuite) Test23Redaction(t *testing.T, db *H) {
+	var buf bytes.Buffer
+	logged := newHandle(t, db,
+		Logger(&buf),
+		RedactPattern("token"),
+	)
+	acc := &Account{}
+	logged.DropTable(acc, nil)
+	if err := logged.CreateTable(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	acc.ID = 1
+	acc.Login = "moe"
+	acc.Password = "hunter2"
+	acc.APIToken = "tk-123"
+	if _, err := logged.Insert(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	acc.Password = "hunter3"
+	if err := logged.Update(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	var accounts []Account
+	if err := logged.Select(&accounts, nil, "WHERE password = @password AND login = @login",
+		sql.Named("password", "hunter3"), sql.Named("login", "moe")); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("expected 1 account got %d", len(accounts))
+	}
+	if _, err := logged.Exec(nil, "UPDATE account SET api_token = @api_token", sql.Named("api_token", "tk-456")); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "hunter3", "tk-123", "tk-456"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %s was logged:\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "moe") || !strings.Contains(out, RedactedValue) {
+		t.Fatalf("unexpected log:\n%s", out)
+	}
+}
+
+func (s *BasicSuite) Test24SlowQueries(t *testing.T, db *H) {
+	var slow []*SlowQuery
+	report := func(q *SlowQuery) {
+		slow = append(slow, q)
+	}
+	watched := newHandle(t, db,
+		ExplainSlowQueries(),
+		SlowQueries(0, report),
+		RedactPattern("name"),
+	)
+	cp := &Company{}
+	watched.DropTable(cp, nil)
+	if err := watched.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	var companies []Company
+	if err := watched.Select(&companies, nil, "WHERE Name = @name", sql.Named("name", "IBM")); err != nil {
+		t.Fatal(err)
+	}
+	if len(slow) != 3 {
+		t.Fatalf("expected 3 slow queries got %d", len(slow))
+	}
+	ddl, sel := slow[1], slow[2]
+	if ddl.Operation != "CreateTable" || ddl.Plan != "" || ddl.PlanErr != nil {
+		t.Fatalf("unexpected slow query %+v", ddl)
+	}
+	if sel.Operation != "Select" || sel.Table != "company" || len(sel.Args) != 1 || sel.Args[0] != RedactedValue {
+		t.Fatalf("unexpected slow query %+v", sel)
+	}
+	if !strings.Contains(sel.Caller, "basic_suite_test.go:") {
+		t.Fatalf("unexpected caller %s", sel.Caller)
+	}
+	if sel.PlanErr != nil || !strings.Contains(strings.ToLower(sel.Plan), "company") {
+		t.Fatalf("unexpected plan %q %v", sel.Plan, sel.PlanErr)
+	}
+	//nothing is reported below the threshold
+	if err := SlowQueries(time.Hour, report)(watched); err != nil {
+		t.Fatal(err)
+	}
+	if err := watched.Select(&companies, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(slow) != 3 {
+		t.Fatalf("expected 3 slow queries got %d", len(slow))
+	}
+	if err := SlowQueries(time.Second, nil)(watched); err == nil {
+		t.Fatal("expected error for nil report function")
+	}
+}
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	id     int
+	parent interface{}
+	info   TraceInfo
+	err    error
+	ended  bool
+}
+
+//recordingTracer stores span ids in contexts and records finished spans
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (rt *recordingTracer) Start(ctx context.Context, info *TraceInfo) context.Context {
+	sp := &recordedSpan{id: len(rt.spans) + 1, parent: ctx.Value(spanKey{}), info: *info}
+	rt.spans = append(rt.spans, sp)
+	return context.WithValue(ctx, spanKey{}, sp.id)
+}
+
+func (rt *recordingTracer) End(ctx context.Context, info *TraceInfo, err error) {
+	sp := rt.spans[ctx.Value(spanKey{}).(int)-1]
+	sp.ended = true
+	sp.err = err
+}
+
+func (s *BasicSuite) Test25Tracing(t *testing.T, db *H) {
+	rt := &recordingTracer{}
+	traced := newHandle(t, db, Tracing(rt))
+	cp := &Company{}
+	traced.DropTable(cp, nil)
+	if err := traced.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	parent := context.WithValue(context.Background(), spanKey{}, 0)
+	tx, err := traced.Begin(WithTxContext(parent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.ID = 1
+	cp.Name = "IBM"
+	if _, err := tx.Insert(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var companies []*Company
+	if err := traced.Select(&companies, WithContext(parent), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := traced.Get(&Company{ID: 42}, nil); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+	var ops []string
+	for _, sp := range rt.spans {
+		ops = append(ops, sp.info.Operation)
+		if !sp.ended {
+			t.Fatalf("span %s was not ended", sp.info.Operation)
+		}
+	}
+	if strings.Join(ops, ",") != "DropTable,CreateTable,Begin,Insert,Commit,Select,Get" {
+		t.Fatalf("unexpected spans %v", ops)
+	}
+	begin, insert, commit, sel, get := rt.spans[2], rt.spans[3], rt.spans[4], rt.spans[5], rt.spans[6]
+	if begin.parent != 0 || begin.info.TxID != tx.ID() || begin.info.TxContext != nil {
+		t.Fatalf("unexpected begin span %+v", begin)
+	}
+	for _, sp := range []*recordedSpan{insert, commit} {
+		if sp.info.TxID != tx.ID() || sp.info.TxContext == nil || sp.info.TxContext.Value(spanKey{}) != begin.id {
+			t.Fatalf("span %s is not linked to its transaction %+v", sp.info.Operation, sp)
+		}
+	}
+	if insert.info.Table != "company" || sel.info.Table != "company" || sel.parent != 0 || sel.info.TxContext != nil {
+		t.Fatalf("unexpected spans %+v %+v", insert, sel)
+	}
+	if get.err != ErrNotFound {
+		t.Fatalf("expected get span to end with ErrNotFound got %v", get.err)
+	}
+}
+
+func (s *BasicSuite) Test26Metrics(t *testing.T, db *H) {
+	mm := NewMemoryMetrics()
+	measured := newHandle(t, db, CollectMetrics(mm))
+	cp := &Company{}
+	measured.DropTable(cp, nil)
+	if err := measured.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"IBM", "Apple"} {
+		if _, err := measured.Insert(&Company{ID: int64(i + 1), Name: name}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var companies []*Company
+	if err := measured.Select(&companies, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := measured.Update(&Company{ID: 1, Name: "IBM Corp"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := measured.Get(&Company{ID: 42}, nil); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+	tx, err := measured.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(&Company{ID: 2}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, st := range mm.Snapshot() {
+		ops = append(ops, st.Operation+":"+st.Table)
+		if st.Latency.Count != st.Calls {
+			t.Fatalf("latency of %s has %d observations for %d calls", st.Operation, st.Latency.Count, st.Calls)
+		}
+	}
+	if strings.Join(ops, ",") != "Begin:,Commit:,CreateTable:company,Delete:company,DropTable:company,Get:company,Insert:company,Select:company,Update:company" {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+	if st := mm.Stats("Insert", "company"); st.Calls != 2 || len(st.Errors) != 0 || st.RowsAffected != 2 {
+		t.Fatalf("unexpected insert stats %+v", st)
+	}
+	if st := mm.Stats("Select", "company"); st.Calls != 1 || st.RowsScanned != 2 {
+		t.Fatalf("unexpected select stats %+v", st)
+	}
+	if st := mm.Stats("Get", "company"); st.Calls != 1 || st.Errors["not_found"] != 1 || st.RowsScanned != 0 {
+		t.Fatalf("unexpected get stats %+v", st)
+	}
+	for _, op := range []string{"Update", "Delete"} {
+		if st := mm.Stats(op, "company"); st.Calls != 1 || st.RowsAffected != 1 {
+			t.Fatalf("unexpected %s stats %+v", op, st)
+		}
+	}
+	mm.Reset()
+	if len(mm.Snapshot()) != 0 {
+		t.Fatal("expected no metrics after Reset")
+	}
+	if err := CollectMetrics(nil)(measured); err == nil {
+		t.Fatal("expected error for nil metrics")
+	}
+}
+
+func (s *BasicSuite) Test27DriverErrors(t *testing.T, db *H) {
+	cp := &Company{ID: 1, Name: "IBM"}
+	db.DropTable(cp, nil)
+	if err := db.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	//insert the same primary key again
+	dup := &Company{ID: 1, Name: "Apple"}
+	if _, err := db.Exec(WithArgs(dup), "INSERT INTO company (ID, Name, Ticker) VALUES (@ID, @Name, @Ticker)"); !errors.Is(err, UniqueViolation) {
+		t.Fatalf("expected unique violation got %v", err)
+	} else {
+		var de *DriverError
+		if !errors.As(err, &de) || !strings.HasPrefix(de.SQL, "INSERT INTO company") || (de.Table != "" && de.Table != "company") {
+			t.Fatalf("unexpected driver error %+v", de)
+		}
+		if errors.Is(err, ForeignKeyViolation) {
+			t.Fatal("unique violation matched foreign key violation")
+		}
+	}
+	db.Exec(nil, "DROP TABLE gadget")
+	if _, err := db.Exec(nil, "CREATE TABLE gadget (id int primary key, name varchar(20) NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(nil, "INSERT INTO gadget (id, name) VALUES (1, NULL)"); !errors.Is(err, NotNullViolation) {
+		t.Fatalf("expected not null violation got %v", err)
+	}
+	if _, err := db.Exec(nil, "DROP TABLE gadget"); err != nil {
+		t.Fatal(err)
+	}
+	//errors not caused by constraints are returned as before
+	if err := db.Get(&Company{ID: 42}, nil); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound got %v", err)
+	}
+}
+
+func (s *BasicSuite) Test28StmtError(t *testing.T, db *H) {
+	acc := &Account{}
+	db.DropTable(acc, nil)
+	if err := db.CreateTable(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	//errors of dbi itself are returned as they are
+	if err := db.Get(&Account{ID: 42}, nil); err != ErrNotFound {
+		t.Fatalf("want %v got %v", ErrNotFound, err)
+	}
+	if err := db.Select(acc, nil, ""); err != ErrNoPointerToSlice {
+		t.Fatalf("want %v got %v", ErrNoPointerToSlice, err)
+	}
+	if err := db.DropTable(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	err := db.Update(&Account{ID: 42, Login: "moe", Password: "hunter2"}, nil)
+	var se *StmtError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected StmtError got %v", err)
+	}
+	if se.Operation != "Update" || se.Table != "account" || !strings.HasPrefix(se.SQL, "UPDATE account") || se.PK != int64(42) || se.TxID != 0 {
+		t.Fatalf("unexpected error %+v", se)
+	}
+	if len(se.Args) != 4 || se.Args[1] != RedactedValue || strings.Contains(fmt.Sprint(se.Args), "hunter2") {
+		t.Fatalf("expected password to be redacted got %v", se.Args)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "Update account (primary key 42): ") || strings.Contains(msg, "hunter2") {
+		t.Fatalf("unexpected message %s", msg)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	err = tx.Get(&Account{ID: 7}, nil)
+	if !errors.As(err, &se) || se.Operation != "Get" || se.TxID != tx.ID() || se.PK != int64(7) {
+		t.Fatalf("unexpected error %+v", err)
+	}
+}
+
+func (s *BasicSuite) Test29StmtCacheSingleConnection(t *testing.T, db *H) {
+	//a transaction holds the only connection, statements must not be prepared via the pool
+	prev := db.DB().Stats().MaxOpenConnections
+	db.DB().SetMaxOpenConns(1)
+	defer db.DB().SetMaxOpenConns(prev)
+	cached := newHandle(t, db, StmtCache(10))
+	defer cached.stmts.close()
+	cp := &Company{}
+	cached.DropTable(cp, nil)
+	if err := cached.CreateTable(cp, nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, err := cached.Begin(WithTxContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cp.ID, cp.Name, cp.Ticker = 1, "IBM", "IBM"
+	if _, err := tx.Insert(cp, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	c := Company{ID: 1}
+	if err := tx.Get(&c, WithContext(ctx)); err != nil || c.Name != "IBM" {
+		t.Fatalf("unexpected company %v %v", c, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	//statements cached outside of the transaction are reused in the next one
+	c = Company{ID: 1}
+	if err := cached.Get(&c, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	tx, err = cached.Begin(WithTxContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	hits := cached.StmtCacheStats().Hits
+	if err := tx.Get(&c, WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+	if cached.StmtCacheStats().Hits != hits+1 {
+		t.Fatalf("expected cached statement to be reused %+v", cached.StmtCacheStats())
+	}
+}
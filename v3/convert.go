@@ -0,0 +1,120 @@
+package dbi
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//convertAssign stores src as returned by the driver into dest the way sql.Rows.Scan does.
+//It is used when a row is scanned into []interface{} first and handed to DBScan afterwards.
+func convertAssign(dest, src interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(src)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("Expected a non-nil pointer to scan into but got %T", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("Converting NULL to %s is unsupported", dv.Type())
+	}
+	if b, ok := src.([]byte); ok {
+		//the driver may reuse the buffer
+		src = append([]byte(nil), b...)
+	}
+	sv := reflect.ValueOf(src)
+	switch {
+	case dv.Kind() == reflect.Interface || sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+		return nil
+	case dv.Kind() == reflect.Ptr:
+		v := reflect.New(dv.Type().Elem())
+		if err := convertAssign(v.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	}
+	text, isText := asText(src)
+	switch dv.Kind() {
+	case reflect.String:
+		if isText {
+			dv.SetString(text)
+			return nil
+		}
+	case reflect.Slice:
+		if dv.Type().Elem().Kind() == reflect.Uint8 && isText {
+			dv.SetBytes([]byte(text))
+			return nil
+		}
+	case reflect.Bool:
+		if !isText {
+			break
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("Converting %q to %s: %v", text, dv.Type(), err)
+		}
+		dv.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !isText {
+			break
+		}
+		n, err := strconv.ParseInt(text, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Converting %q to %s: %v", text, dv.Type(), err)
+		}
+		dv.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !isText {
+			break
+		}
+		n, err := strconv.ParseUint(text, 10, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Converting %q to %s: %v", text, dv.Type(), err)
+		}
+		dv.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if !isText {
+			break
+		}
+		f, err := strconv.ParseFloat(text, dv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Converting %q to %s: %v", text, dv.Type(), err)
+		}
+		dv.SetFloat(f)
+		return nil
+	}
+	return fmt.Errorf("Unsupported conversion of %T into %s", src, dv.Type())
+}
+
+//asText returns the textual form of a driver value the same way database/sql formats values for string destinations
+func asText(src interface{}) (string, bool) {
+	switch v := src.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
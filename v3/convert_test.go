@@ -0,0 +1,66 @@
+package dbi
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConvertAssign(t *testing.T) {
+	ts := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	var (
+		i     int
+		i8    int8
+		u     uint32
+		f     float32
+		s     string
+		b     []byte
+		ok    bool
+		at    time.Time
+		pi    *int
+		ns    sql.NullString
+		iface interface{}
+		name  = "Moe"
+	)
+	var tests = []struct {
+		dest interface{}
+		src  interface{}
+		want interface{}
+	}{
+		{&i, int64(42), 42},
+		{&i, []byte("42"), 42},
+		{&u, "7", uint32(7)},
+		{&f, 1.5, float32(1.5)},
+		{&s, []byte("Doe"), "Doe"},
+		{&s, int64(3), "3"},
+		{&b, "xy", []byte("xy")},
+		{&ok, int64(1), true},
+		{&ok, []byte("false"), false},
+		{&at, ts, ts},
+		{&pi, int64(5), func() *int { v := 5; return &v }()},
+		{&pi, nil, (*int)(nil)},
+		{&ns, "Moe", sql.NullString{String: "Moe", Valid: true}},
+		{&ns, nil, sql.NullString{}},
+		{&iface, int64(1), int64(1)},
+		{&name, nil, nil},
+		{&i8, int64(300), nil},
+		{&i, ts, nil},
+	}
+	for _, test := range tests {
+		err := convertAssign(test.dest, test.src)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%T from %#v: expected error", test.dest, test.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%T from %#v: %v", test.dest, test.src, err)
+			continue
+		}
+		if got := reflect.ValueOf(test.dest).Elem().Interface(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%T from %#v: want %#v got %#v", test.dest, test.src, test.want, got)
+		}
+	}
+}
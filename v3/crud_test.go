@@ -145,6 +145,21 @@ func localizeLogger(db *H, t *testing.T) (*H, error) {
 	return db, err
 }
 
+//newHandle returns a new handle of the same dialect as db using its connection and configured with options,
+//tests needing extra options use it instead of copying db which would share its internal state
+func newHandle(t *testing.T, db *H, options ...DBOption) *H {
+	t.Helper()
+	opts := []func(*H) error{map[string]DBOption{"postgres": Postgres(), "mysql": Mysql()}[db.Dialect()]}
+	for _, opt := range options {
+		opts = append(opts, opt)
+	}
+	h, err := New(db.DB(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
 //executes each test method os suite in its own subtest
 func runSuite(t *testing.T, db *H, suite interface{}) {
 	rv := reflect.ValueOf(suite)
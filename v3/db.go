@@ -6,9 +6,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"reflect"
+	"regexp"
 )
 
 func guessSQLType(c Col) string {
@@ -199,19 +198,23 @@ const (
 
 //H is our handle supporting Insert/Get/Update to be used by client
 type H struct {
-	conn           *sql.DB
-	lw             io.Writer
-	placeholder    func() placeHolderFunc
-	dbType         dbTyp
-	namedArgPrefix rune
+	conn        *sql.DB
+	logger      QueryLogger
+	redact      []*regexp.Regexp
+	slow        *slowQueries
+	tracer      Tracer
+	metrics     Metrics
+	placeholder func() placeHolderFunc
+	dbType      dbTyp
+	syntax      argSyntax
+	stmts       *stmtCache
 }
 
 func newH(conn *sql.DB) *H {
 	return &H{
-		conn:           conn,
-		lw:             ioutil.Discard,
-		placeholder:    defaultPlaceHolder,
-		namedArgPrefix: '@',
+		conn:        conn,
+		placeholder: defaultPlaceHolder,
+		syntax:      argSyntax{prefix: '@'},
 	}
 }
 
@@ -234,12 +237,26 @@ func (db *H) DB() *sql.DB {
 	return db.conn
 }
 
+//Dialect returns the SQL dialect of the handle set via options: sqlite (the default), postgres or mysql
+func (db *H) Dialect() string {
+	switch db.dbType {
+	case postgres:
+		return "postgres"
+	case mysql:
+		return "mysql"
+	}
+	return "sqlite"
+}
+
 //CreateTable executes CREATE TABLE as per DBRow()
 func (db *H) CreateTable(source DBRowMarshaler, optionFunc StmtOption) error {
 	qc := StmtContext{}
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
+	if err := noPreload(&qc); err != nil {
+		return err
+	}
 	var buf bytes.Buffer
 	buf.WriteString("CREATE TABLE ")
 	buf.WriteString(source.DBName())
@@ -253,9 +270,12 @@ func (db *H) CreateTable(source DBRowMarshaler, optionFunc StmtOption) error {
 		buf.WriteString(guessSQLType(c))
 	}
 	buf.WriteString(")")
+	db.startOp(&qc, "CreateTable", source)
+	ql := db.queryLogger()
+	ev := newQueryEvent(ql, &qc, "CreateTable", source, buf.String(), nil, nil)
 	_, err := db.conn.ExecContext(qc.context, buf.String())
-	fmt.Fprintln(db.lw, buf.String())
-	return err
+	err = logQuery(ql, ev, err)
+	return db.endOp(&qc, err)
 }
 
 //DropTable executes DROP TABLE
@@ -264,12 +284,18 @@ func (db *H) DropTable(source DBNamer, optionFunc StmtOption) error {
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
+	if err := noPreload(&qc); err != nil {
+		return err
+	}
 	var buf bytes.Buffer
 	buf.WriteString("DROP TABLE ")
 	buf.WriteString(source.DBName())
-	fmt.Fprintln(db.lw, buf.String())
+	db.startOp(&qc, "DropTable", source)
+	ql := db.queryLogger()
+	ev := newQueryEvent(ql, &qc, "DropTable", source, buf.String(), nil, nil)
 	_, err := db.conn.ExecContext(qc.context, buf.String())
-	return err
+	err = logQuery(ql, ev, err)
+	return db.endOp(&qc, err)
 }
 
 //Named is just a convenience method to avoid the need to import database/sql
@@ -285,6 +311,8 @@ const (
 	NoInsert ColOptFlag = 1 << (16 - 1 - iota)
 	//PrimaryKey marks this column as primary key
 	PrimaryKey
+	//Sensitive means values of this column are masked in logged statements
+	Sensitive
 )
 
 //ColOpt is struct for optional meta information
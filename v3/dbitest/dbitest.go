@@ -0,0 +1,544 @@
+//Package dbitest provides DB, an in-memory fake of dbi.Querier for testing code using dbi without a database.
+//
+//Models are stored by table (DBName) and primary key as returned by DBRow and read back via DBScan.
+//Where clauses of Select, SelectOne, Count, Exists, UpdateWhere and DeleteWhere support conditions
+//joined by AND using =, <>, !=, <, <=, >, >=, IN, NOT IN, LIKE, NOT LIKE, IS NULL and IS NOT NULL
+//comparing a column with a named argument (@name) or a literal, followed by ORDER BY, LIMIT and OFFSET.
+//Operations running arbitrary SQL return ErrUnsupported.
+//
+//Recorder is a fake database/sql driver for asserting the exact SQL dbi generates,
+//it records every statement and replies with scripted rows, results and errors.
+package dbitest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jlabath/dbi/v3"
+)
+
+//ErrUnsupported is returned by operations running arbitrary SQL such as Exec or SelectSQL
+var ErrUnsupported = errors.New("Operation is not supported by dbitest")
+
+//ErrTxDone is returned by Commit and Rollback of a transaction which already ended
+var ErrTxDone = errors.New("Transaction has already been committed or rolled back")
+
+//record is a single stored row
+type record struct {
+	cols []dbi.Col
+}
+
+//value returns value of column name, column names are case insensitive as in SQL
+func (r *record) value(name string) interface{} {
+	if c := findCol(r.cols, name); c != nil {
+		return c.Val
+	}
+	return nil
+}
+
+func (r *record) clone() *record {
+	c := &record{cols: make([]dbi.Col, len(r.cols))}
+	for i, col := range r.cols {
+		col.Val = copyValue(col.Val)
+		c.cols[i] = col
+	}
+	return c
+}
+
+//copyValue copies byte slices so that stored rows do not share memory with models
+func copyValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok && b != nil {
+		return append([]byte(nil), b...)
+	}
+	return v
+}
+
+func findCol(cols []dbi.Col, name string) *dbi.Col {
+	for i := range cols {
+		if strings.EqualFold(cols[i].Name, name) {
+			return &cols[i]
+		}
+	}
+	return nil
+}
+
+func pkCol(cols []dbi.Col) *dbi.Col {
+	for i := range cols {
+		if hasFlag(cols[i], dbi.PrimaryKey) {
+			return &cols[i]
+		}
+	}
+	return nil
+}
+
+func hasFlag(c dbi.Col, flag dbi.ColOptFlag) bool {
+	return c.Opt != nil && c.Opt.Flags&flag == flag
+}
+
+//table holds rows of a single table in insertion order
+type table struct {
+	rows   []*record
+	lastID int64 // last generated primary key
+}
+
+func (t *table) clone() *table {
+	c := &table{rows: make([]*record, len(t.rows)), lastID: t.lastID}
+	for i, r := range t.rows {
+		c.rows[i] = r.clone()
+	}
+	return c
+}
+
+//find returns index of row with primary key col equal to pk or -1
+func (t *table) find(col string, pk interface{}) int {
+	want := normalize(pk)
+	for i, r := range t.rows {
+		if n, err := compare(normalize(r.value(col)), want); err == nil && n == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+//fake implements the operations shared by DB and Tx
+type fake struct {
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+//DB is an in-memory fake of dbi.Querier, the zero value is an empty database ready to use
+type DB struct {
+	fake
+}
+
+//New returns an empty DB
+func New() *DB {
+	return &DB{}
+}
+
+var (
+	_ dbi.Querier   = (*DB)(nil)
+	_ dbi.TxQuerier = (*Tx)(nil)
+)
+
+//Tx is a transaction of DB working on a copy of its tables which replaces them on Commit.
+//Transactions are not isolated from each other, the last one to commit wins.
+type Tx struct {
+	fake
+	db   *DB
+	done bool
+}
+
+//Begin starts a transaction
+func (db *DB) Begin() (*Tx, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	tx := &Tx{db: db}
+	tx.tables = make(map[string]*table, len(db.tables))
+	for name, t := range db.tables {
+		tx.tables[name] = t.clone()
+	}
+	return tx, nil
+}
+
+//Commit replaces tables of DB with the tables of this transaction
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.db.mu.Lock()
+	tx.db.tables = tx.tables
+	tx.db.mu.Unlock()
+	return nil
+}
+
+//Rollback discards changes made in this transaction
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	return nil
+}
+
+//Reset removes all rows of tables, all tables when none are given
+func (db *DB) Reset(tables ...string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(tables) == 0 {
+		db.tables = nil
+		return
+	}
+	for _, name := range tables {
+		delete(db.tables, name)
+	}
+}
+
+//Len returns the number of rows stored in table
+func (db *DB) Len(table string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if t, ok := db.tables[table]; ok {
+		return len(t.rows)
+	}
+	return 0
+}
+
+func (f *fake) table(name string) *table {
+	if f.tables == nil {
+		f.tables = make(map[string]*table)
+	}
+	t, ok := f.tables[name]
+	if !ok {
+		t = &table{}
+		f.tables[name] = t
+	}
+	return t
+}
+
+//applyOption runs optionFunc so that its errors are reported as by dbi, the options themselves are ignored
+func applyOption(optionFunc dbi.StmtOption) error {
+	if optionFunc == nil {
+		return nil
+	}
+	var qc dbi.StmtContext
+	return optionFunc(&qc)
+}
+
+//fail wraps err in dbi.StmtError as dbi does for failed statements, errors of dbi itself are returned as they are
+func fail(op, table string, pk interface{}, err error) error {
+	var de *dbi.DriverError
+	if !errors.As(err, &de) {
+		return err
+	}
+	return &dbi.StmtError{Operation: op, Table: table, PK: pk, Err: err}
+}
+
+//primaryKey returns primary key column of row of model s
+func primaryKey(op string, s dbi.DBNamer, row []dbi.Col) (*dbi.Col, error) {
+	pk := pkCol(row)
+	if pk == nil {
+		return nil, fail(op, s.DBName(), nil, dbi.ErrNoPrimaryKey)
+	}
+	return pk, nil
+}
+
+//Insert stores s, primary keys flagged NoInsert or not set are generated as by an auto increment column
+func (f *fake) Insert(s dbi.DBRowMarshaler, optionFunc dbi.StmtOption) (dbi.Col, error) {
+	if err := applyOption(optionFunc); err != nil {
+		return dbi.Col{}, err
+	}
+	if err := dbi.Validate(context.Background(), s); err != nil {
+		return dbi.Col{}, fail("Insert", s.DBName(), nil, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.table(s.DBName())
+	r := (&record{cols: s.DBRow()}).clone()
+	pk := pkCol(r.cols)
+	if pk == nil {
+		t.rows = append(t.rows, r)
+		return dbi.Col{}, nil
+	}
+	id, isInt := normalize(pk.Val).(int64)
+	if hasFlag(*pk, dbi.NoInsert) || pk.Val == nil || (isInt && id == 0) {
+		t.lastID++
+		val := reflect.ValueOf(t.lastID)
+		if pk.Val != nil && val.Type().ConvertibleTo(reflect.TypeOf(pk.Val)) {
+			val = val.Convert(reflect.TypeOf(pk.Val))
+		}
+		pk.Val = val.Interface()
+	} else if t.find(pk.Name, pk.Val) >= 0 {
+		return *pk, fail("Insert", s.DBName(), pk.Val, &dbi.DriverError{
+			Kind:  dbi.UniqueViolation,
+			Table: s.DBName(),
+			Err:   fmt.Errorf("Duplicate primary key %v in %s", pk.Val, s.DBName()),
+		})
+	} else if isInt && id > t.lastID {
+		t.lastID = id
+	}
+	t.rows = append(t.rows, r)
+	return *pk, nil
+}
+
+//Get scans row with the primary key of s into s
+func (f *fake) Get(s dbi.DBRowUnmarshaler, optionFunc dbi.StmtOption) error {
+	if err := applyOption(optionFunc); err != nil {
+		return err
+	}
+	row := s.DBRow()
+	pk, err := primaryKey("Get", s, row)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.table(s.DBName())
+	i := t.find(pk.Name, pk.Val)
+	if i < 0 {
+		return fail("Get", s.DBName(), pk.Val, dbi.ErrNotFound)
+	}
+	if err := scan(s, t.rows[i]); err != nil {
+		return fail("Get", s.DBName(), pk.Val, err)
+	}
+	return nil
+}
+
+//Update replaces row with the primary key of s
+func (f *fake) Update(s dbi.DBRowUnmarshaler, optionFunc dbi.StmtOption) error {
+	if err := applyOption(optionFunc); err != nil {
+		return err
+	}
+	row := s.DBRow()
+	pk, err := primaryKey("Update", s, row)
+	if err != nil {
+		return err
+	}
+	if err := dbi.Validate(context.Background(), s); err != nil {
+		return fail("Update", s.DBName(), pk.Val, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.table(s.DBName())
+	i := t.find(pk.Name, pk.Val)
+	if i < 0 {
+		return fail("Update", s.DBName(), pk.Val, dbi.ErrNotFound)
+	}
+	t.rows[i] = (&record{cols: row}).clone()
+	return nil
+}
+
+//Delete removes row with the primary key of s if any
+func (f *fake) Delete(s dbi.DBRowMarshaler, optionFunc dbi.StmtOption) error {
+	if err := applyOption(optionFunc); err != nil {
+		return err
+	}
+	row := s.DBRow()
+	pk, err := primaryKey("Delete", s, row)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := f.table(s.DBName())
+	if i := t.find(pk.Name, pk.Val); i >= 0 {
+		t.rows = append(t.rows[:i], t.rows[i+1:]...)
+	}
+	return nil
+}
+
+//matching returns rows of table matching where
+func (f *fake) matching(name string, where string, args []sql.NamedArg) ([]*record, error) {
+	c, err := parseClause(where, args)
+	if err != nil {
+		return nil, err
+	}
+	var res []*record
+	for _, r := range f.table(name).rows {
+		ok, err := c.match(r)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res = append(res, r)
+		}
+	}
+	if err := sortRecords(res, c.order); err != nil {
+		return nil, err
+	}
+	if c.offset > 0 {
+		if c.offset > len(res) {
+			c.offset = len(res)
+		}
+		res = res[c.offset:]
+	}
+	if c.limit >= 0 && c.limit < len(res) {
+		res = res[:c.limit]
+	}
+	return res, nil
+}
+
+//UpdateWhere sets setCols of rows matching where and returns their number
+func (f *fake) UpdateWhere(s dbi.DBNamer, optionFunc dbi.StmtOption, setCols []dbi.Col, where string, args ...sql.NamedArg) (int64, error) {
+	if err := applyOption(optionFunc); err != nil {
+		return 0, err
+	}
+	if len(setCols) == 0 {
+		return 0, fail("UpdateWhere", s.DBName(), nil, dbi.ErrNoColumns)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows, err := f.matching(s.DBName(), where, args)
+	if err != nil {
+		return 0, fail("UpdateWhere", s.DBName(), nil, err)
+	}
+	for _, r := range rows {
+		for _, set := range setCols {
+			if c := findCol(r.cols, set.Name); c != nil {
+				c.Val = copyValue(set.Val)
+			} else {
+				r.cols = append(r.cols, dbi.Col{Name: set.Name, Val: copyValue(set.Val)})
+			}
+		}
+	}
+	return int64(len(rows)), nil
+}
+
+//DeleteWhere removes rows matching where and returns their number
+func (f *fake) DeleteWhere(s dbi.DBNamer, optionFunc dbi.StmtOption, where string, args ...sql.NamedArg) (int64, error) {
+	if err := applyOption(optionFunc); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows, err := f.matching(s.DBName(), where, args)
+	if err != nil {
+		return 0, fail("DeleteWhere", s.DBName(), nil, err)
+	}
+	deleted := make(map[*record]bool, len(rows))
+	for _, r := range rows {
+		deleted[r] = true
+	}
+	t := f.table(s.DBName())
+	kept := t.rows[:0]
+	for _, r := range t.rows {
+		if !deleted[r] {
+			kept = append(kept, r)
+		}
+	}
+	t.rows = kept
+	return int64(len(rows)), nil
+}
+
+//Select appends rows matching where to dst which must be a pointer to a slice of models
+func (f *fake) Select(dst interface{}, optionFunc dbi.StmtOption, where string, args ...sql.NamedArg) error {
+	if err := applyOption(optionFunc); err != nil {
+		return err
+	}
+	dstv := reflect.ValueOf(dst)
+	if dstv.Kind() != reflect.Ptr || dstv.Elem().Kind() != reflect.Slice {
+		return fail("Select", "", nil, dbi.ErrNoPointerToSlice)
+	}
+	slice := dstv.Elem()
+	newModel, err := modelMaker(slice.Type().Elem())
+	if err != nil {
+		return fail("Select", "", nil, err)
+	}
+	name := newModel().Interface().(dbi.DBNamer).DBName()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows, err := f.matching(name, where, args)
+	if err != nil {
+		return fail("Select", name, nil, err)
+	}
+	for _, r := range rows {
+		m := newModel()
+		if err := scan(m.Interface().(dbi.DBScanner), r); err != nil {
+			return fail("Select", name, nil, err)
+		}
+		if slice.Type().Elem().Kind() != reflect.Ptr {
+			m = m.Elem()
+		}
+		slice.Set(reflect.Append(slice, m))
+	}
+	return nil
+}
+
+//modelMaker returns function creating pointers to new models of slice element type typ
+func modelMaker(typ reflect.Type) (func() reflect.Value, error) {
+	base := typ
+	if base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if !reflect.PtrTo(base).Implements(reflect.TypeOf((*dbi.DBRowUnmarshaler)(nil)).Elem()) {
+		return nil, dbi.ErrNoUnmarshaler
+	}
+	return func() reflect.Value { return reflect.New(base) }, nil
+}
+
+//SelectOne scans the single row matching where into dst
+func (f *fake) SelectOne(dst dbi.DBRowUnmarshaler, optionFunc dbi.StmtOption, where string, args ...sql.NamedArg) error {
+	if err := applyOption(optionFunc); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows, err := f.matching(dst.DBName(), where, args)
+	switch {
+	case err != nil:
+	case len(rows) == 0:
+		err = dbi.ErrNotFound
+	case len(rows) > 1:
+		err = dbi.ErrMultipleRows
+	default:
+		err = scan(dst, rows[0])
+	}
+	if err != nil {
+		return fail("SelectOne", dst.DBName(), nil, err)
+	}
+	return nil
+}
+
+//Count returns the number of rows matching where
+func (f *fake) Count(s dbi.DBNamer, optionFunc dbi.StmtOption, where string, args ...sql.NamedArg) (int64, error) {
+	return f.count("Count", s, optionFunc, where, args)
+}
+
+//Exists reports whether any row matches where
+func (f *fake) Exists(s dbi.DBNamer, optionFunc dbi.StmtOption, where string, args ...sql.NamedArg) (bool, error) {
+	n, err := f.count("Exists", s, optionFunc, where, args)
+	return n > 0, err
+}
+
+func (f *fake) count(op string, s dbi.DBNamer, optionFunc dbi.StmtOption, where string, args []sql.NamedArg) (int64, error) {
+	if err := applyOption(optionFunc); err != nil {
+		return 0, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows, err := f.matching(s.DBName(), where, args)
+	if err != nil {
+		return 0, fail(op, s.DBName(), nil, err)
+	}
+	return int64(len(rows)), nil
+}
+
+//SelectSQL returns ErrUnsupported
+func (f *fake) SelectSQL(dst interface{}, optionFunc dbi.StmtOption, query string, args ...sql.NamedArg) error {
+	return fail("SelectSQL", "", nil, ErrUnsupported)
+}
+
+//SelectValues returns ErrUnsupported
+func (f *fake) SelectValues(dst interface{}, optionFunc dbi.StmtOption, query string, args ...sql.NamedArg) error {
+	return fail("SelectValues", "", nil, ErrUnsupported)
+}
+
+//SelectJoin returns ErrUnsupported
+func (f *fake) SelectJoin(dst *[]dbi.Tuple, optionFunc dbi.StmtOption, prototypes []dbi.DBRowUnmarshaler, from string, args ...sql.NamedArg) error {
+	return fail("SelectJoin", "", nil, ErrUnsupported)
+}
+
+//SelectPage returns ErrUnsupported
+func (f *fake) SelectPage(dst interface{}, optionFunc dbi.StmtOption, sort []dbi.Sort, size int, cursor string, filter string, args ...sql.NamedArg) (dbi.Page, error) {
+	return dbi.Page{}, fail("SelectPage", "", nil, ErrUnsupported)
+}
+
+//Exec returns ErrUnsupported
+func (f *fake) Exec(optionFunc dbi.StmtOption, query string, args ...sql.NamedArg) (sql.Result, error) {
+	return nil, fail("Exec", "", nil, ErrUnsupported)
+}
+
+//QueryRaw returns ErrUnsupported
+func (f *fake) QueryRaw(optionFunc dbi.StmtOption, query string, args ...sql.NamedArg) (*sql.Rows, error) {
+	return nil, fail("QueryRaw", "", nil, ErrUnsupported)
+}
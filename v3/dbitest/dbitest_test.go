@@ -0,0 +1,233 @@
+package dbitest
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jlabath/dbi/v3"
+)
+
+var pkMeta = &dbi.ColOpt{Type: "INTEGER PRIMARY KEY", Flags: dbi.NoInsert | dbi.PrimaryKey}
+
+type Person struct {
+	ID        int
+	FirstName string
+	LastName  string
+	Age       int
+	Nick      sql.NullString
+}
+
+func (p *Person) DBName() string {
+	return "person"
+}
+
+func (p *Person) DBRow() []dbi.Col {
+	return []dbi.Col{
+		{Name: "id", Val: p.ID, Opt: pkMeta},
+		{Name: "first", Val: p.FirstName},
+		{Name: "last", Val: p.LastName, Opt: &dbi.ColOpt{Type: "varchar(10)"}},
+		{Name: "age", Val: p.Age},
+		{Name: "nick", Val: p.Nick},
+	}
+}
+
+func (p *Person) DBScan(scanner dbi.Scanner) error {
+	return scanner.Scan(&p.ID, &p.FirstName, &p.LastName, &p.Age, &p.Nick)
+}
+
+//rename is an example of service code working with both dbi.H and dbi.Tx
+func rename(q dbi.Querier, id int, first string) error {
+	p := &Person{ID: id}
+	if err := q.Get(p, nil); err != nil {
+		return err
+	}
+	p.FirstName = first
+	return q.Update(p, nil)
+}
+
+func seed(t *testing.T, q dbi.Querier) {
+	for _, p := range []*Person{
+		{FirstName: "John", LastName: "Doe", Age: 40},
+		{FirstName: "Jane", LastName: "Doe", Age: 35, Nick: sql.NullString{String: "JD", Valid: true}},
+		{FirstName: "Moe", LastName: "Szyslak", Age: 50},
+	} {
+		if _, err := q.Insert(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	db := New()
+	seed(t, db)
+	col, err := db.Insert(&Person{ID: 42, FirstName: "Homer", LastName: "Simpson"}, nil)
+	if err != nil || col.Val != 4 {
+		t.Fatalf("expected generated primary key 4 got %v %v", col.Val, err)
+	}
+	if err := rename(db, 2, "Janet"); err != nil {
+		t.Fatal(err)
+	}
+	p := &Person{ID: 2}
+	if err := db.Get(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.FirstName != "Janet" || p.LastName != "Doe" || p.Nick.String != "JD" {
+		t.Fatalf("unexpected person %+v", p)
+	}
+	if err := rename(db, 7, "Nobody"); err != dbi.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+	if _, ok := db.Update(&Person{ID: 1, LastName: "Longer than ten"}, nil).(*dbi.ValidationError); !ok {
+		t.Fatalf("expected validation error got %v", err)
+	}
+	if err := db.Delete(&Person{ID: 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Get(&Person{ID: 1}, nil); err != dbi.ErrNotFound {
+		t.Fatalf("expected not found got %v", err)
+	}
+	if db.Len("person") != 3 {
+		t.Fatalf("expected 3 rows got %d", db.Len("person"))
+	}
+	db.Reset()
+	if db.Len("person") != 0 {
+		t.Fatal("expected no rows after Reset")
+	}
+}
+
+func TestUniqueViolation(t *testing.T) {
+	db := New()
+	acc := &account{Login: "moe"}
+	if _, err := db.Insert(acc, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Insert(acc, nil)
+	var se *dbi.StmtError
+	if !errors.Is(err, dbi.UniqueViolation) || !errors.As(err, &se) || se.Operation != "Insert" {
+		t.Fatalf("expected unique violation got %v", err)
+	}
+}
+
+type account struct {
+	Login string
+}
+
+func (a *account) DBName() string {
+	return "account"
+}
+
+func (a *account) DBRow() []dbi.Col {
+	return []dbi.Col{{Name: "login", Val: a.Login, Opt: &dbi.ColOpt{Flags: dbi.PrimaryKey}}}
+}
+
+func (a *account) DBScan(scanner dbi.Scanner) error {
+	return scanner.Scan(&a.Login)
+}
+
+func TestWhere(t *testing.T) {
+	db := New()
+	seed(t, db)
+	cases := []struct {
+		where string
+		args  []sql.NamedArg
+		want  []int
+	}{
+		{"", nil, []int{1, 2, 3}},
+		{"WHERE last = @last", []sql.NamedArg{sql.Named("last", "Doe")}, []int{1, 2}},
+		{"WHERE last = 'Doe' AND age > 36", nil, []int{1}},
+		{"WHERE age >= @min AND age <= @max ORDER BY age DESC", []sql.NamedArg{sql.Named("min", 35), sql.Named("max", 40)}, []int{1, 2}},
+		{"WHERE id IN (@ids)", []sql.NamedArg{sql.Named("ids", []int{1, 3})}, []int{1, 3}},
+		{"WHERE id NOT IN (1, 3)", nil, []int{2}},
+		{"WHERE first LIKE 'J%' ORDER BY first", nil, []int{2, 1}},
+		{"WHERE nick IS NULL", nil, []int{1, 3}},
+		{"WHERE person.nick IS NOT NULL", nil, []int{2}},
+		{"WHERE last <> 'Doe'", nil, []int{3}},
+		{"ORDER BY last DESC, first LIMIT 2", nil, []int{3, 2}},
+		{"ORDER BY id LIMIT 1 OFFSET 1", nil, []int{2}},
+	}
+	for _, c := range cases {
+		var people []Person
+		if err := db.Select(&people, nil, c.where, c.args...); err != nil {
+			t.Fatalf("%s: %v", c.where, err)
+		}
+		var ids []int
+		for _, p := range people {
+			ids = append(ids, p.ID)
+		}
+		if len(ids) != len(c.want) {
+			t.Fatalf("%s: expected %v got %v", c.where, c.want, ids)
+		}
+		for i := range ids {
+			if ids[i] != c.want[i] {
+				t.Fatalf("%s: expected %v got %v", c.where, c.want, ids)
+			}
+		}
+	}
+	var people []*Person
+	for _, where := range []string{"WHERE age > @missing", "WHERE first OR last", "GROUP BY last"} {
+		if err := db.Select(&people, nil, where); err == nil {
+			t.Fatalf("expected error for %s", where)
+		}
+	}
+	if err := db.Select(&people, nil, "WHERE id IN (@ids)", sql.Named("ids", []int{})); !errors.Is(err, dbi.ErrEmptySlice) {
+		t.Fatalf("expected ErrEmptySlice got %v", err)
+	}
+	one := &Person{}
+	if err := db.SelectOne(one, nil, "WHERE last = 'Doe'"); !errors.Is(err, dbi.ErrMultipleRows) {
+		t.Fatalf("expected multiple rows got %v", err)
+	}
+	if err := db.SelectOne(one, nil, "WHERE first = 'Moe'"); err != nil || one.ID != 3 {
+		t.Fatalf("unexpected %+v %v", one, err)
+	}
+	if n, err := db.Count(&Person{}, nil, "WHERE last = 'Doe'"); err != nil || n != 2 {
+		t.Fatalf("expected 2 got %d %v", n, err)
+	}
+	if ok, err := db.Exists(&Person{}, nil, "WHERE age > 60"); err != nil || ok {
+		t.Fatalf("expected false got %v %v", ok, err)
+	}
+	if n, err := db.UpdateWhere(&Person{}, nil, []dbi.Col{{Name: "last", Val: "Smith"}}, "WHERE last = 'Doe'"); err != nil || n != 2 {
+		t.Fatalf("expected 2 got %d %v", n, err)
+	}
+	if n, err := db.DeleteWhere(&Person{}, nil, "WHERE last = 'Smith' AND age < 40"); err != nil || n != 1 {
+		t.Fatalf("expected 1 got %d %v", n, err)
+	}
+	if n, _ := db.Count(&Person{}, nil, "WHERE last = 'Smith'"); n != 1 {
+		t.Fatalf("expected 1 got %d", n)
+	}
+	if _, err := db.Exec(nil, "DELETE FROM person"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported got %v", err)
+	}
+}
+
+func TestTx(t *testing.T) {
+	db := New()
+	seed(t, db)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rename(tx, 1, "Johnny"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	p := &Person{ID: 1}
+	if err := db.Get(p, nil); err != nil || p.FirstName != "John" {
+		t.Fatalf("expected rolled back change got %+v %v", p, err)
+	}
+	tx, _ = db.Begin()
+	if err := rename(tx, 1, "Johnny"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Fatalf("expected ErrTxDone got %v", err)
+	}
+	if err := db.Get(p, nil); err != nil || p.FirstName != "Johnny" {
+		t.Fatalf("expected committed change got %+v %v", p, err)
+	}
+}
@@ -0,0 +1,504 @@
+package dbitest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//DriverName is the name the fake driver of Recorder is registered with in database/sql
+const DriverName = "dbitest"
+
+//recorders by DSN used by the registered driver to find the Recorder of a connection
+var recorders sync.Map
+
+var recorderCounter uint64
+
+func init() {
+	sql.Register(DriverName, fakeDriver{})
+}
+
+//kind of a recorded statement
+type stmtKind int
+
+const (
+	execStmt stmtKind = iota
+	queryStmt
+	txStmt
+)
+
+func (k stmtKind) String() string {
+	switch k {
+	case execStmt:
+		return "Exec"
+	case queryStmt:
+		return "Query"
+	}
+	return "Tx"
+}
+
+//Statement is a single statement received by the fake driver
+type Statement struct {
+	SQL  string
+	Args []driver.Value
+	kind stmtKind
+}
+
+func (s Statement) String() string {
+	if len(s.Args) == 0 {
+		return s.SQL
+	}
+	return fmt.Sprintf("%s %s", s.SQL, formatArgs(s.Args))
+}
+
+func formatArgs(args []driver.Value) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case string:
+			parts[i] = strconv.Quote(v)
+		case []byte:
+			parts[i] = fmt.Sprintf("[]byte(%q)", v)
+		case ArgMatcher:
+			parts[i] = v.String()
+		default:
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+//ArgMatcher matches an argument of a statement when its exact value is not known in advance e.g. a timestamp
+type ArgMatcher interface {
+	Match(v driver.Value) bool
+	String() string
+}
+
+type anyArg struct{}
+
+func (anyArg) Match(v driver.Value) bool { return true }
+func (anyArg) String() string            { return "<any>" }
+
+//AnyArg returns ArgMatcher matching any value
+func AnyArg() ArgMatcher {
+	return anyArg{}
+}
+
+//Expectation is a statement Recorder expects to receive and the response it should give
+type Expectation struct {
+	stmt     Statement
+	anyArgs  bool
+	columns  []string
+	rows     [][]driver.Value
+	result   driver.Result
+	err      error
+	received bool
+}
+
+//WithAnyArgs makes the expectation match the statement regardless of its arguments
+func (e *Expectation) WithAnyArgs() *Expectation {
+	e.anyArgs = true
+	return e
+}
+
+//WillReturnResult sets the result of an expected Exec
+func (e *Expectation) WillReturnResult(lastInsertID, rowsAffected int64) *Expectation {
+	e.result = result{lastInsertID: lastInsertID, rowsAffected: rowsAffected}
+	return e
+}
+
+//WillReturnResultError sets the result of an expected Exec to one whose LastInsertId and RowsAffected fail with err
+func (e *Expectation) WillReturnResultError(err error) *Expectation {
+	e.result = result{err: err}
+	return e
+}
+
+//WillReturnRows sets rows returned by an expected Query, each row holds one value per column
+func (e *Expectation) WillReturnRows(columns []string, rows ...[]interface{}) *Expectation {
+	e.columns = columns
+	e.rows = make([][]driver.Value, len(rows))
+	for i, row := range rows {
+		e.rows[i] = make([]driver.Value, len(row))
+		for j, v := range row {
+			dv, err := driver.DefaultParameterConverter.ConvertValue(v)
+			if err != nil {
+				panic(fmt.Sprintf("dbitest: row %d column %d: %v", i, j, err))
+			}
+			e.rows[i][j] = dv
+		}
+	}
+	return e
+}
+
+//WillReturnError makes the expected statement fail with err
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+//Recorder is a fake database/sql driver connection recording every statement it receives.
+//Without any expectations every statement succeeds, Exec affects no rows and Query returns no rows.
+//Once expectations are set statements must arrive in the expected order, mismatches fail the statement
+//with an error describing the difference and are reported by Check.
+type Recorder struct {
+	dsn      string
+	mu       sync.Mutex
+	expected []*Expectation
+	recorded []Statement
+	errs     []error
+}
+
+//NewRecorder returns a new Recorder
+func NewRecorder() *Recorder {
+	r := &Recorder{dsn: fmt.Sprintf("recorder-%d", atomic.AddUint64(&recorderCounter, 1))}
+	recorders.Store(r.dsn, r)
+	return r
+}
+
+//DSN returns data source name to be used with sql.Open(DriverName, dsn)
+func (r *Recorder) DSN() string {
+	return r.dsn
+}
+
+//DB returns sql.DB connected to r
+func (r *Recorder) DB() *sql.DB {
+	db, err := sql.Open(DriverName, r.dsn)
+	if err != nil {
+		//the driver is registered in init and Open does not connect
+		panic(err)
+	}
+	return db
+}
+
+func (r *Recorder) expect(kind stmtKind, query string, args []interface{}) *Expectation {
+	e := &Expectation{stmt: Statement{SQL: query, kind: kind}}
+	for _, a := range args {
+		if m, ok := a.(ArgMatcher); ok {
+			e.stmt.Args = append(e.stmt.Args, m)
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(a)
+		if err != nil {
+			panic(fmt.Sprintf("dbitest: argument %v: %v", a, err))
+		}
+		e.stmt.Args = append(e.stmt.Args, v)
+	}
+	r.mu.Lock()
+	r.expected = append(r.expected, e)
+	r.mu.Unlock()
+	return e
+}
+
+//ExpectExec expects the next statement to be executed via Exec with args.
+//Whitespace in query is not significant.
+func (r *Recorder) ExpectExec(query string, args ...interface{}) *Expectation {
+	return r.expect(execStmt, query, args)
+}
+
+//ExpectQuery expects the next statement to be a query with args.
+//Whitespace in query is not significant.
+func (r *Recorder) ExpectQuery(query string, args ...interface{}) *Expectation {
+	return r.expect(queryStmt, query, args)
+}
+
+//ExpectBegin expects a transaction to be started
+func (r *Recorder) ExpectBegin() *Expectation {
+	return r.expect(txStmt, "BEGIN", nil)
+}
+
+//ExpectCommit expects a transaction to be committed
+func (r *Recorder) ExpectCommit() *Expectation {
+	return r.expect(txStmt, "COMMIT", nil)
+}
+
+//ExpectRollback expects a transaction to be rolled back
+func (r *Recorder) ExpectRollback() *Expectation {
+	return r.expect(txStmt, "ROLLBACK", nil)
+}
+
+//Statements returns statements received so far
+func (r *Recorder) Statements() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Statement(nil), r.recorded...)
+}
+
+//Check returns error describing statements which did not match expectations and expectations not received
+func (r *Recorder) Check() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []string
+	for _, err := range r.errs {
+		msgs = append(msgs, err.Error())
+	}
+	for _, e := range r.expected {
+		if !e.received {
+			msgs = append(msgs, fmt.Sprintf("expected %s was not received: %s", e.stmt.kind, e.stmt))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+//Verify reports error returned by Check to t
+func (r *Recorder) Verify(t testing.TB) {
+	t.Helper()
+	if err := r.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+//Reset discards recorded statements, expectations and errors
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expected, r.recorded, r.errs = nil, nil, nil
+}
+
+//receive records statement and returns the expectation it matches, nil when no expectations were set
+func (r *Recorder) receive(s Statement) (*Expectation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded = append(r.recorded, s)
+	if len(r.expected) == 0 {
+		return nil, nil
+	}
+	var next *Expectation
+	for _, e := range r.expected {
+		if !e.received {
+			next = e
+			break
+		}
+	}
+	n := len(r.recorded)
+	var err error
+	if next == nil {
+		err = fmt.Errorf("dbitest: unexpected %s #%d: %s", s.kind, n, s)
+	} else if msg := mismatch(next, s); msg != "" {
+		err = fmt.Errorf("dbitest: %s #%d does not match expectation\n%s", s.kind, n, msg)
+	}
+	if err != nil {
+		r.errs = append(r.errs, err)
+		return nil, err
+	}
+	next.received = true
+	return next, next.err
+}
+
+//normalizeSQL collapses whitespace so that formatting of queries is not significant
+func normalizeSQL(q string) string {
+	return strings.Join(strings.Fields(q), " ")
+}
+
+//mismatch returns readable description of differences between expectation e and statement s, empty when they match
+func mismatch(e *Expectation, s Statement) string {
+	var lines []string
+	if e.stmt.kind != s.kind {
+		lines = append(lines, fmt.Sprintf("expected %s but got %s", e.stmt.kind, s.kind))
+	}
+	want, got := normalizeSQL(e.stmt.SQL), normalizeSQL(s.SQL)
+	if want != got {
+		i := 0
+		for i < len(want) && i < len(got) && want[i] == got[i] {
+			i++
+		}
+		lines = append(lines,
+			"expected: "+want,
+			"     got: "+got,
+			"          "+strings.Repeat(" ", i)+"^")
+	}
+	if !e.anyArgs {
+		if len(e.stmt.Args) != len(s.Args) {
+			lines = append(lines, fmt.Sprintf("expected %d args %s but got %d args %s",
+				len(e.stmt.Args), formatArgs(e.stmt.Args), len(s.Args), formatArgs(s.Args)))
+		} else {
+			for i, w := range e.stmt.Args {
+				if !argEqual(w, s.Args[i]) {
+					lines = append(lines, fmt.Sprintf("arg %d: expected %s but got %s",
+						i+1, formatArgs([]driver.Value{w}), formatArgs([]driver.Value{s.Args[i]})))
+				}
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func argEqual(want, got driver.Value) bool {
+	if m, ok := want.(ArgMatcher); ok {
+		return m.Match(got)
+	}
+	if w, ok := want.(time.Time); ok {
+		g, ok := got.(time.Time)
+		return ok && w.Equal(g)
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+type result struct {
+	lastInsertID int64
+	rowsAffected int64
+	err          error
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, r.err
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, r.err
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	r, ok := recorders.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("dbitest: no Recorder with DSN %s", dsn)
+	}
+	return &fakeConn{r: r.(*Recorder)}, nil
+}
+
+type fakeConn struct {
+	r *Recorder
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, err := c.r.receive(Statement{SQL: "BEGIN", kind: txStmt}); err != nil {
+		return nil, err
+	}
+	return &fakeTx{c: c}, nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	if len(args) == 0 {
+		return nil
+	}
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	return vals
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, err := c.r.receive(Statement{SQL: query, Args: namedValues(args), kind: execStmt})
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.result == nil {
+		return result{}, nil
+	}
+	return e.result, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	e, err := c.r.receive(Statement{SQL: query, Args: namedValues(args), kind: queryStmt})
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{columns: e.columns, rows: e.rows}, nil
+}
+
+type fakeTx struct {
+	c *fakeConn
+}
+
+func (tx *fakeTx) Commit() error {
+	_, err := tx.c.r.receive(Statement{SQL: "COMMIT", kind: txStmt})
+	return err
+}
+
+func (tx *fakeTx) Rollback() error {
+	_, err := tx.c.r.receive(Statement{SQL: "ROLLBACK", kind: txStmt})
+	return err
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func toNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return named
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.c.ExecContext(context.Background(), s.query, toNamed(args))
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.c.QueryContext(context.Background(), s.query, toNamed(args))
+}
+
+func (s *fakeStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *fakeStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	if len(row) != len(dest) {
+		return fmt.Errorf("dbitest: row has %d values but query has %d columns", len(row), len(dest))
+	}
+	copy(dest, row)
+	return nil
+}
@@ -0,0 +1,89 @@
+package dbitest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jlabath/dbi/v3"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	db, err := dbi.New(r.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ExpectBegin()
+	r.ExpectExec("INSERT INTO person(first,last,age,nick) VALUES (?,?,?,?)", "John", "Doe", 40, nil).WillReturnResult(7, 1)
+	r.ExpectCommit()
+	r.ExpectQuery("SELECT id,first,last,age,nick FROM person WHERE id=?", 7).
+		WillReturnRows([]string{"id", "first", "last", "age", "nick"}, []interface{}{7, "John", "Doe", 40, "JD"})
+	r.ExpectExec("DELETE FROM person WHERE id=?", AnyArg()).WillReturnResult(0, 1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := tx.Insert(&Person{FirstName: "John", LastName: "Doe", Age: 40}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk.Val != 7 {
+		t.Fatalf("expected primary key 7 got %v", pk.Val)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	p := &Person{ID: 7}
+	if err := db.Get(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.FirstName != "John" || p.Age != 40 || p.Nick.String != "JD" {
+		t.Fatalf("unexpected person %+v", p)
+	}
+	if err := db.Delete(p, nil); err != nil {
+		t.Fatal(err)
+	}
+	r.Verify(t)
+	if n := len(r.Statements()); n != 5 {
+		t.Fatalf("expected 5 statements got %d", n)
+	}
+}
+
+func TestRecorderMismatch(t *testing.T) {
+	r := NewRecorder()
+	db, err := dbi.New(r.DB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.ExpectExec("UPDATE person SET first=?,last=?,age=?,nick=? WHERE id=?", "John", "Doe", 40, nil, 1).WillReturnResult(0, 1)
+	err = db.Update(&Person{ID: 1, FirstName: "John", LastName: "Moe", Age: 40}, nil)
+	if err == nil || !strings.Contains(err.Error(), `arg 2: expected ["Doe"] but got ["Moe"]`) {
+		t.Fatalf("expected argument mismatch got %v", err)
+	}
+	check := r.Check()
+	if check == nil || !strings.Contains(check.Error(), "expected Exec was not received: UPDATE person") {
+		t.Fatalf("expected Check to report mismatch got %v", check)
+	}
+	r.Reset()
+	r.ExpectExec("DELETE FROM persons WHERE id=?", 1)
+	err = db.Delete(&Person{ID: 1}, nil)
+	diff := strings.Join([]string{
+		"expected: DELETE FROM persons WHERE id=?",
+		"     got: DELETE FROM person WHERE id=?",
+		"                            ^",
+	}, "\n")
+	if err == nil || !strings.Contains(err.Error(), diff) {
+		t.Fatalf("expected diff\n%s\ngot\n%v", diff, err)
+	}
+	r.Reset()
+	r.ExpectExec("INSERT INTO person(first,last,age,nick) VALUES (?,?,?,?)").WithAnyArgs().WillReturnResultError(errors.New("busted"))
+	r.ExpectQuery("SELECT id FROM person WHERE first=? AND last=? AND age=? AND nick=? ORDER BY id DESC", "Ann", "Lee", 20, nil).
+		WillReturnRows([]string{"id"}, []interface{}{3})
+	pk, err := db.Insert(&Person{FirstName: "Ann", LastName: "Lee", Age: 20}, nil)
+	if err != nil || pk.Val != 3 {
+		t.Fatalf("expected primary key 3 from fallback query got %v %v", pk.Val, err)
+	}
+	r.Verify(t)
+}
@@ -0,0 +1,481 @@
+package dbitest
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jlabath/dbi/v3"
+	"gopkg.in/yaml.v3"
+)
+
+//Fixtures are rows to be inserted into tables, read from YAML or JSON documents keyed by table name.
+//Rows of a table are either a list or a map keyed by a symbolic name of the row:
+//
+//	company:
+//	  ibm:
+//	    name: IBM
+//	annual_report:
+//	  - company_id: $company.ibm
+//	    year: 2019
+//
+//A string value $table.name is a reference replaced by the primary key of the named row of table,
+//rows are inserted in dependency order so that referenced rows are inserted first.
+//A string starting with $$ is inserted as is without the first $.
+//Lists and maps are inserted as JSON text.
+//Table and column names are put into statements as they are so only letters, digits and underscores are accepted,
+//tables may be qualified by a schema.
+type Fixtures struct {
+	tables []*fixtureTable
+	pks    map[string]string // primary key column by table
+}
+
+type fixtureTable struct {
+	name string
+	rows []*fixtureRow
+}
+
+type fixtureRow struct {
+	table string
+	name  string // symbolic name, empty for rows given as a list
+	cols  []string
+	vals  []interface{}
+	refs  []*fixtureRow // rows referenced by this row
+	pk    interface{}   // primary key once inserted and referenced
+	used  bool          // whether other rows reference this row
+}
+
+var (
+	tableRegexp  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	columnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+//ReadFixtures reads fixtures from YAML (.yml, .yaml) or JSON (.json) files
+func ReadFixtures(paths ...string) (*Fixtures, error) {
+	f := &Fixtures{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Add(data); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return f, nil
+}
+
+//Add adds fixtures from YAML or JSON document data
+func (f *Fixtures) Add(data []byte) error {
+	//JSON is a subset of YAML
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return errors.New("Expected fixtures to be a mapping of table names to rows")
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		name, rows := root.Content[i].Value, root.Content[i+1]
+		if !tableRegexp.MatchString(name) {
+			return fmt.Errorf("Invalid table name %q at line %d", name, root.Content[i].Line)
+		}
+		t := f.table(name)
+		switch rows.Kind {
+		case yaml.SequenceNode:
+			for _, n := range rows.Content {
+				if err := f.addRow(t, "", n); err != nil {
+					return err
+				}
+			}
+		case yaml.MappingNode:
+			for j := 0; j < len(rows.Content); j += 2 {
+				if err := f.addRow(t, rows.Content[j].Value, rows.Content[j+1]); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("Expected rows of table %s to be a list or a mapping at line %d", name, rows.Line)
+		}
+	}
+	return nil
+}
+
+func (f *Fixtures) table(name string) *fixtureTable {
+	for _, t := range f.tables {
+		if t.name == name {
+			return t
+		}
+	}
+	t := &fixtureTable{name: name}
+	f.tables = append(f.tables, t)
+	return t
+}
+
+func (f *Fixtures) addRow(t *fixtureTable, name string, n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("Expected row of table %s to be a mapping of columns at line %d", t.name, n.Line)
+	}
+	if name != "" && f.find(t.name, name) != nil {
+		return fmt.Errorf("Duplicate row %s of table %s at line %d", name, t.name, n.Line)
+	}
+	r := &fixtureRow{table: t.name, name: name}
+	for i := 0; i < len(n.Content); i += 2 {
+		if col := n.Content[i].Value; !columnRegexp.MatchString(col) {
+			return fmt.Errorf("Invalid column name %q of table %s at line %d", col, t.name, n.Content[i].Line)
+		}
+		var v interface{}
+		if err := n.Content[i+1].Decode(&v); err != nil {
+			return err
+		}
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return fmt.Errorf("Column %s of table %s at line %d: %v", n.Content[i].Value, t.name, n.Content[i].Line, err)
+			}
+			v = string(b)
+		}
+		r.cols = append(r.cols, n.Content[i].Value)
+		r.vals = append(r.vals, v)
+	}
+	t.rows = append(t.rows, r)
+	return nil
+}
+
+func (f *Fixtures) find(table, name string) *fixtureRow {
+	for _, t := range f.tables {
+		if t.name != table {
+			continue
+		}
+		for _, r := range t.rows {
+			if r.name == name {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+//PrimaryKey sets the primary key column of table used to resolve references, it is id by default
+func (f *Fixtures) PrimaryKey(table, column string) *Fixtures {
+	if f.pks == nil {
+		f.pks = make(map[string]string)
+	}
+	f.pks[table] = column
+	return f
+}
+
+//Models sets the primary key columns of tables of models to their PrimaryKey columns
+func (f *Fixtures) Models(models ...dbi.DBRowMarshaler) *Fixtures {
+	for _, m := range models {
+		if pk := pkCol(m.DBRow()); pk != nil {
+			f.PrimaryKey(m.DBName(), pk.Name)
+		}
+	}
+	return f
+}
+
+func (f *Fixtures) primaryKey(table string) string {
+	if pk, ok := f.pks[table]; ok {
+		return pk
+	}
+	return "id"
+}
+
+//Ref returns primary key of row name of table once it was loaded
+func (f *Fixtures) Ref(table, name string) (interface{}, bool) {
+	r := f.find(table, name)
+	if r == nil || r.pk == nil {
+		return nil, false
+	}
+	return r.pk, true
+}
+
+//resolve links references of all rows to the referenced rows
+func (f *Fixtures) resolve() error {
+	for _, t := range f.tables {
+		for _, r := range t.rows {
+			r.refs = nil
+			for i, v := range r.vals {
+				s, ok := v.(string)
+				if !ok || !strings.HasPrefix(s, "$") || strings.HasPrefix(s, "$$") {
+					continue
+				}
+				dot := strings.Index(s, ".")
+				if dot < 0 {
+					return fmt.Errorf("Reference %s in column %s of table %s is not of form $table.name", s, r.cols[i], r.table)
+				}
+				ref := f.find(s[1:dot], s[dot+1:])
+				if ref == nil {
+					return fmt.Errorf("Reference %s in column %s of table %s points to unknown row", s, r.cols[i], r.table)
+				}
+				ref.used = true
+				r.refs = append(r.refs, ref)
+			}
+		}
+	}
+	return nil
+}
+
+//order returns rows in order of insertion so that referenced rows come before rows referencing them,
+//the order of rows in documents is kept otherwise
+func (f *Fixtures) order() ([]*fixtureRow, error) {
+	if err := f.resolve(); err != nil {
+		return nil, err
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*fixtureRow]int)
+	var (
+		res   []*fixtureRow
+		visit func(r *fixtureRow) error
+	)
+	visit = func(r *fixtureRow) error {
+		switch state[r] {
+		case visiting:
+			return fmt.Errorf("Cyclic reference of row %s of table %s", r.name, r.table)
+		case done:
+			return nil
+		}
+		state[r] = visiting
+		for _, ref := range r.refs {
+			if err := visit(ref); err != nil {
+				return err
+			}
+		}
+		state[r] = done
+		res = append(res, r)
+		return nil
+	}
+	for _, t := range f.tables {
+		for _, r := range t.rows {
+			if err := visit(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+//tableOrder returns tables in order of first insertion of their rows
+func tableOrder(rows []*fixtureRow) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, r := range rows {
+		if !seen[r.table] {
+			seen[r.table] = true
+			tables = append(tables, r.table)
+		}
+	}
+	return tables
+}
+
+//foreignKeys returns statements disabling and enabling foreign key checks in a transaction of dialect
+func foreignKeys(dialect string) (disable, enable string) {
+	switch dialect {
+	case "postgres":
+		//only constraints declared DEFERRABLE can be deferred
+		return "SET CONSTRAINTS ALL DEFERRED", ""
+	case "mysql":
+		return "SET FOREIGN_KEY_CHECKS = 0", "SET FOREIGN_KEY_CHECKS = 1"
+	}
+	return "PRAGMA defer_foreign_keys = ON", ""
+}
+
+//inTx runs fn in a transaction with foreign key checks disabled where supported
+func inTx(db *dbi.H, fn func(tx *sql.Tx) error) error {
+	tx, err := db.DB().Begin()
+	if err != nil {
+		return err
+	}
+	disable, enable := foreignKeys(db.Dialect())
+	err = func() error {
+		if _, err := tx.Exec(disable); err != nil {
+			return err
+		}
+		return fn(tx)
+	}()
+	//the MySQL setting belongs to the session and survives a rollback,
+	//enable the checks again before the connection returns to the pool
+	if enable != "" {
+		if _, eerr := tx.Exec(enable); eerr != nil && err == nil {
+			err = eerr
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//checkPrimaryKeys returns an error if primary key column of any of tables is not a valid column name
+func (f *Fixtures) checkPrimaryKeys(tables []string) error {
+	for _, table := range tables {
+		if pk := f.primaryKey(table); !columnRegexp.MatchString(pk) {
+			return fmt.Errorf("Invalid primary key column %q of table %s", pk, table)
+		}
+	}
+	return nil
+}
+
+//Load inserts all rows in a single transaction in dependency order.
+//On Postgres sequences of primary keys given explicitly are advanced past the inserted values.
+func (f *Fixtures) Load(db *dbi.H) error {
+	rows, err := f.order()
+	if err != nil {
+		return err
+	}
+	tables := tableOrder(rows)
+	if err := f.checkPrimaryKeys(tables); err != nil {
+		return err
+	}
+	explicit := make(map[string]bool)
+	for _, r := range rows {
+		r.pk = nil
+		for _, c := range r.cols {
+			if strings.EqualFold(c, f.primaryKey(r.table)) {
+				explicit[r.table] = true
+			}
+		}
+	}
+	return inTx(db, func(tx *sql.Tx) error {
+		for _, r := range rows {
+			if err := f.insert(tx, db.Dialect(), r); err != nil {
+				return fmt.Errorf("Fixture %s of table %s: %v", r.name, r.table, err)
+			}
+		}
+		if db.Dialect() != "postgres" {
+			return nil
+		}
+		for _, table := range tables {
+			if !explicit[table] {
+				continue
+			}
+			pk := f.primaryKey(table)
+			err := withSequence(tx, table, pk, func(seq string) error {
+				_, err := tx.Exec("SELECT setval($1, MAX("+pk+")) FROM "+table, seq)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("Sequence of table %s: %v", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+//serialSequence looks up the sequence of a Postgres column without failing for unknown columns
+const serialSequence = `SELECT pg_get_serial_sequence($1::text, attname::text) FROM pg_attribute
+WHERE attrelid = $1::text::regclass AND attname = lower($2::text) AND NOT attisdropped`
+
+//withSequence calls fn with the sequence generating column pk of table if there is one
+func withSequence(tx *sql.Tx, table, pk string, fn func(seq string) error) error {
+	var seq sql.NullString
+	err := tx.QueryRow(serialSequence, table, pk).Scan(&seq)
+	if err == sql.ErrNoRows || (err == nil && !seq.Valid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fn(seq.String)
+}
+
+func (f *Fixtures) insert(tx *sql.Tx, dialect string, r *fixtureRow) error {
+	pkName := f.primaryKey(r.table)
+	args := make([]interface{}, len(r.vals))
+	var (
+		buf          strings.Builder
+		placeholders []string
+	)
+	for i, v := range r.vals {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, "$") {
+			if strings.HasPrefix(s, "$$") {
+				v = s[1:]
+			} else {
+				dot := strings.Index(s, ".")
+				v = f.find(s[1:dot], s[dot+1:]).pk
+			}
+		}
+		args[i] = v
+		if strings.EqualFold(r.cols[i], pkName) {
+			r.pk = v
+		}
+		if dialect == "postgres" {
+			placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		} else {
+			placeholders = append(placeholders, "?")
+		}
+	}
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(r.table)
+	buf.WriteString(" (")
+	buf.WriteString(strings.Join(r.cols, ","))
+	buf.WriteString(") VALUES (")
+	buf.WriteString(strings.Join(placeholders, ","))
+	buf.WriteString(")")
+	//rows given as a list can not be referenced or looked up via Ref
+	if r.pk != nil || (r.name == "" && !r.used) {
+		_, err := tx.Exec(buf.String(), args...)
+		return err
+	}
+	//the primary key is generated by the database and needed to resolve references
+	if dialect == "postgres" {
+		buf.WriteString(" RETURNING ")
+		buf.WriteString(pkName)
+		return tx.QueryRow(buf.String(), args...).Scan(&r.pk)
+	}
+	res, err := tx.Exec(buf.String(), args...)
+	if err != nil {
+		return err
+	}
+	r.pk, err = res.LastInsertId()
+	return err
+}
+
+//Reset deletes all rows of tables of the fixtures in a single transaction, tables referencing others first.
+//On Postgres sequences of primary keys are restarted,
+//AUTO_INCREMENT counters of MySQL and AUTOINCREMENT ones of SQLite are left as they are.
+func (f *Fixtures) Reset(db *dbi.H) error {
+	rows, err := f.order()
+	if err != nil {
+		return err
+	}
+	tables := tableOrder(rows)
+	if err := f.checkPrimaryKeys(tables); err != nil {
+		return err
+	}
+	return inTx(db, func(tx *sql.Tx) error {
+		for i := len(tables) - 1; i >= 0; i-- {
+			if _, err := tx.Exec("DELETE FROM " + tables[i]); err != nil {
+				return err
+			}
+		}
+		if db.Dialect() != "postgres" {
+			return nil
+		}
+		for _, table := range tables {
+			err := withSequence(tx, table, f.primaryKey(table), func(seq string) error {
+				_, err := tx.Exec("SELECT setval($1, 1, false)", seq)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("Sequence of table %s: %v", table, err)
+			}
+		}
+		return nil
+	})
+}
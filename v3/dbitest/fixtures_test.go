@@ -0,0 +1,252 @@
+package dbitest
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jlabath/dbi/v3"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var fixtureSchema = []string{
+	`CREATE TABLE company (id INTEGER PRIMARY KEY, name TEXT NOT NULL, ticker TEXT, parent_id INTEGER REFERENCES company(id))`,
+	`CREATE TABLE annual_report (id INTEGER PRIMARY KEY, company_id INTEGER NOT NULL REFERENCES company(id), year INTEGER, revenue REAL, meta TEXT)`,
+	`CREATE TABLE employee (emp_no INTEGER PRIMARY KEY, company_id INTEGER NOT NULL REFERENCES company(id), name TEXT, boss_id INTEGER REFERENCES employee(emp_no))`,
+}
+
+func fixtureDB(t *testing.T) *dbi.H {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "fixtures.db")+"?_foreign_keys=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for _, stmt := range fixtureSchema {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := dbi.New(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func count(t *testing.T, db *dbi.H, table string) int {
+	t.Helper()
+	var n int
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestFixtures(t *testing.T) {
+	db := fixtureDB(t)
+	f, err := ReadFixtures("testdata/companies.yml", "testdata/people.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PrimaryKey("employee", "emp_no")
+	if err := f.Load(db); err != nil {
+		t.Fatal(err)
+	}
+	for table, n := range map[string]int{"company": 2, "annual_report": 2, "employee": 2} {
+		if c := count(t, db, table); c != n {
+			t.Errorf("Expected %d rows in %s but got %d", n, table, c)
+		}
+	}
+	ibm, ok := f.Ref("company", "ibm")
+	if !ok {
+		t.Fatal("Expected reference to company ibm")
+	}
+	if acme, _ := f.Ref("company", "acme"); acme != 42 {
+		t.Errorf("Expected explicit primary key 42 but got %v", acme)
+	}
+	var (
+		ticker string
+		parent int64
+	)
+	if err := db.DB().QueryRow("SELECT c.ticker, a.parent_id FROM company c, company a WHERE c.id = ? AND a.id = 42", ibm).Scan(&ticker, &parent); err != nil {
+		t.Fatal(err)
+	}
+	if ticker != "$IBM" || parent != ibm {
+		t.Errorf("Unexpected ticker %q and parent %d", ticker, parent)
+	}
+	var meta string
+	if err := db.DB().QueryRow("SELECT meta FROM annual_report WHERE company_id = 42").Scan(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta != `{"audited":true,"notes":["q1","q4"]}` {
+		t.Errorf("Unexpected meta %s", meta)
+	}
+	boss, _ := f.Ref("employee", "boss")
+	var bossID int64
+	if err := db.DB().QueryRow("SELECT boss_id FROM employee WHERE name = 'Wile E. Coyote'").Scan(&bossID); err != nil {
+		t.Fatal(err)
+	}
+	if bossID != boss {
+		t.Errorf("Expected boss %v but got %d", boss, bossID)
+	}
+	//named rows nobody references can be looked up too
+	wile, ok := f.Ref("employee", "wile")
+	var wileID int64
+	if err := db.DB().QueryRow("SELECT emp_no FROM employee WHERE name = 'Wile E. Coyote'").Scan(&wileID); err != nil {
+		t.Fatal(err)
+	}
+	if !ok || wile != wileID {
+		t.Errorf("Expected reference to employee wile %d but got %v", wileID, wile)
+	}
+
+	if err := f.Reset(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"company", "annual_report", "employee"} {
+		if c := count(t, db, table); c != 0 {
+			t.Errorf("Expected %s to be empty after Reset but got %d rows", table, c)
+		}
+	}
+	//fixtures can be loaded again after reset
+	if err := f.Load(db); err != nil {
+		t.Fatal(err)
+	}
+	if c := count(t, db, "employee"); c != 2 {
+		t.Errorf("Expected 2 employees after reload but got %d", c)
+	}
+}
+
+func TestFixturesRollback(t *testing.T) {
+	db := fixtureDB(t)
+	f := &Fixtures{}
+	err := f.Add([]byte(`
+company:
+  a: {name: A}
+annual_report:
+  - {company_id: $company.a, nope: 1}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Load(db); err == nil {
+		t.Fatal("Expected error inserting unknown column")
+	}
+	if c := count(t, db, "company"); c != 0 {
+		t.Errorf("Expected load to be rolled back but got %d companies", c)
+	}
+}
+
+func TestFixturesErrors(t *testing.T) {
+	db := fixtureDB(t)
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{`[1, 2]`, "mapping of table names"},
+		{`company: 1`, "list or a mapping"},
+		{`company: [1]`, "mapping of columns"},
+		{"company:\n  a: {name: A}\ncompany:\n  a: {name: B}", "Duplicate row a"},
+		{`company: [{parent_id: $company}]`, "not of form"},
+		{`company: [{parent_id: $company.x}]`, "unknown row"},
+		{"company:\n  a: {parent_id: $company.b}\n  b: {parent_id: $company.a}", "Cyclic reference"},
+		{`"company; DROP TABLE company": [{name: A}]`, "Invalid table name"},
+		{`company: [{"name) VALUES ('x'); --": A}]`, "Invalid column name"},
+	}
+	for _, tc := range tests {
+		f := &Fixtures{}
+		err := f.Add([]byte(tc.doc))
+		if err == nil {
+			err = f.Load(db)
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected error containing %q for %q but got %v", tc.err, tc.doc, err)
+		}
+	}
+}
+
+func TestFixturesPostgresSequences(t *testing.T) {
+	r := NewRecorder()
+	db, err := dbi.New(r.DB(), dbi.Postgres())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fixtures{}
+	if err := f.Add([]byte("company:\n  acme: {id: 42, name: Acme}\ntag: [{name: x}]")); err != nil {
+		t.Fatal(err)
+	}
+	r.ExpectBegin()
+	r.ExpectExec("SET CONSTRAINTS ALL DEFERRED").WillReturnResult(0, 0)
+	r.ExpectExec("INSERT INTO company (id,name) VALUES ($1,$2)", 42, "Acme").WillReturnResult(0, 1)
+	r.ExpectExec("INSERT INTO tag (name) VALUES ($1)", "x").WillReturnResult(0, 1)
+	//explicit primary keys do not advance the sequence
+	r.ExpectQuery(serialSequence, "company", "id").WillReturnRows([]string{"seq"}, []interface{}{"public.company_id_seq"})
+	r.ExpectExec("SELECT setval($1, MAX(id)) FROM company", "public.company_id_seq").WillReturnResult(0, 0)
+	r.ExpectCommit()
+	if err := f.Load(db); err != nil {
+		t.Fatal(err)
+	}
+	r.Verify(t)
+	r.Reset()
+	r.ExpectBegin()
+	r.ExpectExec("SET CONSTRAINTS ALL DEFERRED").WillReturnResult(0, 0)
+	r.ExpectExec("DELETE FROM tag").WillReturnResult(0, 1)
+	r.ExpectExec("DELETE FROM company").WillReturnResult(0, 1)
+	r.ExpectQuery(serialSequence, "company", "id").WillReturnRows([]string{"seq"}, []interface{}{"public.company_id_seq"})
+	r.ExpectExec("SELECT setval($1, 1, false)", "public.company_id_seq").WillReturnResult(0, 0)
+	//tables without a generated primary key are skipped
+	r.ExpectQuery(serialSequence, "tag", "id").WillReturnRows([]string{"seq"})
+	r.ExpectCommit()
+	if err := f.Reset(db); err != nil {
+		t.Fatal(err)
+	}
+	r.Verify(t)
+	f.PrimaryKey("tag", "id; --")
+	if err := f.Reset(db); err == nil || !strings.Contains(err.Error(), "Invalid primary key column") {
+		t.Fatalf("Expected invalid primary key error but got %v", err)
+	}
+}
+
+func TestFixturesMysqlForeignKeys(t *testing.T) {
+	r := NewRecorder()
+	db, err := dbi.New(r.DB(), dbi.Mysql())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Fixtures{}
+	if err := f.Add([]byte("company: [{name: Acme}]")); err != nil {
+		t.Fatal(err)
+	}
+	//checks are enabled again on the session even when the load fails
+	r.ExpectBegin()
+	r.ExpectExec("SET FOREIGN_KEY_CHECKS = 0").WillReturnResult(0, 0)
+	r.ExpectExec("INSERT INTO company (name) VALUES (?)", "Acme").WillReturnError(errors.New("boom"))
+	r.ExpectExec("SET FOREIGN_KEY_CHECKS = 1").WillReturnResult(0, 0)
+	r.ExpectRollback()
+	if err := f.Load(db); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Expected insert error but got %v", err)
+	}
+	r.Verify(t)
+	r.Reset()
+	r.ExpectBegin()
+	r.ExpectExec("SET FOREIGN_KEY_CHECKS = 0").WillReturnError(errors.New("denied"))
+	r.ExpectExec("SET FOREIGN_KEY_CHECKS = 1").WillReturnResult(0, 0)
+	r.ExpectRollback()
+	if err := f.Load(db); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("Expected error disabling checks but got %v", err)
+	}
+	r.Verify(t)
+}
+
+func TestFixturesModels(t *testing.T) {
+	f := (&Fixtures{}).Models(&Person{})
+	if pk := f.primaryKey("person"); pk != "id" {
+		t.Errorf("Expected primary key id but got %s", pk)
+	}
+	f.PrimaryKey("person", "person_id")
+	if pk := f.primaryKey("person"); pk != "person_id" {
+		t.Errorf("Expected primary key person_id but got %s", pk)
+	}
+}
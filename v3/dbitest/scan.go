@@ -0,0 +1,104 @@
+package dbitest
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/jlabath/dbi/v3"
+)
+
+//rowScanner is dbi.Scanner returning stored values in the order of columns of the scanned model
+type rowScanner struct {
+	values []interface{}
+}
+
+//scan reads r into model m
+func scan(m dbi.DBScanner, r *record) error {
+	var values []interface{}
+	if rm, ok := m.(dbi.DBRowMarshaler); ok {
+		for _, c := range rm.DBRow() {
+			values = append(values, copyValue(r.value(c.Name)))
+		}
+	}
+	return m.DBScan(&rowScanner{values: values})
+}
+
+func (rs *rowScanner) Scan(dest ...interface{}) error {
+	if len(dest) != len(rs.values) {
+		return fmt.Errorf("Expected %d destination arguments in Scan, not %d", len(rs.values), len(dest))
+	}
+	for i, d := range dest {
+		if err := assign(d, rs.values[i]); err != nil {
+			return fmt.Errorf("Scan error on column index %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+//assign stores src in dest similar to what database/sql does for values returned by drivers
+func assign(dest, src interface{}) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(driverValue(src))
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination %T is not a non-nil pointer", dest)
+	}
+	dv = dv.Elem()
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("converting NULL to %s is unsupported", dv.Type())
+	}
+	sv := reflect.ValueOf(src)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+		return nil
+	case dv.Kind() == reflect.Ptr:
+		v := reflect.New(dv.Type().Elem())
+		if err := assign(v.Interface(), src); err != nil {
+			return err
+		}
+		dv.Set(v)
+		return nil
+	case convertible(sv.Type(), dv.Type()):
+		dv.Set(sv.Convert(dv.Type()))
+		return nil
+	}
+	return fmt.Errorf("unsupported conversion of %T into %s", src, dv.Type())
+}
+
+//convertible reports whether values of type from can be converted to to without changing their meaning
+func convertible(from, to reflect.Type) bool {
+	if !from.ConvertibleTo(to) {
+		return false
+	}
+	isNum := func(t reflect.Type) bool {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			return true
+		}
+		return false
+	}
+	isText := func(t reflect.Type) bool {
+		return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	}
+	return (isNum(from) && isNum(to)) || (isText(from) && isText(to)) || from.Kind() == to.Kind()
+}
+
+//driverValue converts v to one of the types drivers return so that it can be passed to sql.Scanner
+func driverValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, int64, float64, bool, []byte, string, time.Time:
+		return x
+	}
+	return normalize(v)
+}
@@ -0,0 +1,589 @@
+package dbitest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jlabath/dbi/v3"
+)
+
+//cond is a single condition of a where clause e.g. age >= @age
+type cond struct {
+	col  string
+	op   string // =, <>, <, <=, >, >=, IN, NOT IN, IS NULL, IS NOT NULL, LIKE, NOT LIKE
+	vals []interface{}
+}
+
+//clause is a parsed where clause as passed to Select and friends
+type clause struct {
+	conds  []cond
+	order  []dbi.Sort
+	limit  int // -1 when not limited
+	offset int
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokArg
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+//tokenize splits where clause into tokens
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '@' || r == ':':
+			j := i + 1
+			for j < len(rs) && isIdent(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{tokArg, string(rs[i+1 : j])})
+			i = j
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, string(rs[i:j])})
+			i = j
+		case r == '\'':
+			var buf strings.Builder
+			j := i + 1
+			for ; j < len(rs); j++ {
+				if rs[j] == '\'' {
+					if j+1 < len(rs) && rs[j+1] == '\'' {
+						buf.WriteRune('\'')
+						j++
+						continue
+					}
+					break
+				}
+				buf.WriteRune(rs[j])
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("Unterminated string in %q", s)
+			}
+			toks = append(toks, token{tokString, buf.String()})
+			i = j + 1
+		case isIdent(r):
+			j := i
+			for j < len(rs) && (isIdent(rs[j]) || rs[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, string(rs[i:j])})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "<>", "!=", "<=", ">=":
+					op = two
+				}
+			}
+			switch op {
+			case "=", "<>", "!=", "<", "<=", ">", ">=", "(", ")", ",":
+			default:
+				return nil, fmt.Errorf("Unsupported character %q in %q", r, s)
+			}
+			toks = append(toks, token{tokOp, op})
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+//parser of the subset of SQL supported in where clauses by DB
+type parser struct {
+	src  string
+	toks []token
+	args []sql.NamedArg
+}
+
+func (p *parser) peek() (token, bool) {
+	if len(p.toks) == 0 {
+		return token{}, false
+	}
+	return p.toks[0], true
+}
+
+func (p *parser) next() (token, error) {
+	t, ok := p.peek()
+	if !ok {
+		return t, fmt.Errorf("Unexpected end of %q", p.src)
+	}
+	p.toks = p.toks[1:]
+	return t, nil
+}
+
+//keyword consumes the next token if it is one of keywords kw (case insensitive)
+func (p *parser) keyword(kw ...string) bool {
+	t, ok := p.peek()
+	if !ok || t.kind != tokIdent || len(p.toks) < len(kw) {
+		return false
+	}
+	for i, k := range kw {
+		if p.toks[i].kind != tokIdent || !strings.EqualFold(p.toks[i].text, k) {
+			return false
+		}
+	}
+	p.toks = p.toks[len(kw):]
+	return true
+}
+
+func (p *parser) expect(op string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != tokOp || t.text != op {
+		return fmt.Errorf("Expected %s but got %s in %q", op, t.text, p.src)
+	}
+	return nil
+}
+
+//parseClause parses where (e.g. "WHERE last = @last AND age > 18 ORDER BY last DESC LIMIT 10")
+//with values of named arguments taken from args
+func parseClause(where string, args []sql.NamedArg) (*clause, error) {
+	toks, err := tokenize(where)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: where, toks: toks, args: args}
+	c := &clause{limit: -1}
+	if p.keyword("WHERE") {
+		for {
+			cd, err := p.cond()
+			if err != nil {
+				return nil, err
+			}
+			c.conds = append(c.conds, cd)
+			if !p.keyword("AND") {
+				break
+			}
+		}
+	}
+	if p.keyword("ORDER", "BY") {
+		for {
+			t, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("Expected column but got %s in %q", t.text, where)
+			}
+			s := dbi.Asc(column(t.text))
+			if p.keyword("DESC") {
+				s.Desc = true
+			} else {
+				p.keyword("ASC")
+			}
+			c.order = append(c.order, s)
+			if t, ok := p.peek(); !ok || t.kind != tokOp || t.text != "," {
+				break
+			}
+			p.toks = p.toks[1:]
+		}
+	}
+	if p.keyword("LIMIT") {
+		if c.limit, err = p.integer(); err != nil {
+			return nil, err
+		}
+		if p.keyword("OFFSET") {
+			if c.offset, err = p.integer(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if t, ok := p.peek(); ok {
+		return nil, fmt.Errorf("Unsupported %s in %q", t.text, where)
+	}
+	return c, nil
+}
+
+func (p *parser) integer() (int, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	v, err := p.value(t)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := normalize(v).(int64)
+	if !ok {
+		return 0, fmt.Errorf("Expected integer but got %v in %q", v, p.src)
+	}
+	return int(n), nil
+}
+
+func (p *parser) cond() (cond, error) {
+	t, err := p.next()
+	if err != nil {
+		return cond{}, err
+	}
+	if t.kind != tokIdent {
+		return cond{}, fmt.Errorf("Expected column but got %s in %q", t.text, p.src)
+	}
+	c := cond{col: column(t.text)}
+	switch {
+	case p.keyword("IS", "NOT", "NULL"):
+		c.op = "IS NOT NULL"
+		return c, nil
+	case p.keyword("IS", "NULL"):
+		c.op = "IS NULL"
+		return c, nil
+	case p.keyword("NOT", "IN"):
+		c.op = "NOT IN"
+		return c, p.list(&c)
+	case p.keyword("IN"):
+		c.op = "IN"
+		return c, p.list(&c)
+	case p.keyword("NOT", "LIKE"):
+		c.op = "NOT LIKE"
+	case p.keyword("LIKE"):
+		c.op = "LIKE"
+	default:
+		op, err := p.next()
+		if err != nil {
+			return c, err
+		}
+		switch op.text {
+		case "=", "<>", "<", "<=", ">", ">=":
+			c.op = op.text
+		case "!=":
+			c.op = "<>"
+		default:
+			return c, fmt.Errorf("Unsupported operator %s in %q", op.text, p.src)
+		}
+	}
+	t, err = p.next()
+	if err != nil {
+		return c, err
+	}
+	v, err := p.value(t)
+	if err != nil {
+		return c, err
+	}
+	c.vals = []interface{}{v}
+	return c, nil
+}
+
+//list parses values of IN, slices and arrays given as a single named argument are expanded as dbi does
+func (p *parser) list(c *cond) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		v, err := p.value(t)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(v)
+		if v != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+			if rv.Len() == 0 {
+				return fmt.Errorf("%w: %s", dbi.ErrEmptySlice, t.text)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				c.vals = append(c.vals, rv.Index(i).Interface())
+			}
+		} else {
+			c.vals = append(c.vals, v)
+		}
+		t, err = p.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokOp && t.text == ")" {
+			return nil
+		}
+		if t.kind != tokOp || t.text != "," {
+			return fmt.Errorf("Expected , or ) but got %s in %q", t.text, p.src)
+		}
+	}
+}
+
+//value returns value of literal or named argument t
+func (p *parser) value(t token) (interface{}, error) {
+	switch t.kind {
+	case tokArg:
+		for _, a := range p.args {
+			if a.Name == t.text {
+				return a.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("Missing value for argument @%s in %q", t.text, p.src)
+	case tokString:
+		return t.text, nil
+	case tokNumber:
+		if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(t.text, 64)
+	case tokIdent:
+		switch strings.ToUpper(t.text) {
+		case "NULL":
+			return nil, nil
+		case "TRUE":
+			return true, nil
+		case "FALSE":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("Expected value but got %s in %q", t.text, p.src)
+}
+
+//column strips table name from qualified column name
+func column(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+//match reports whether r satisfies all conditions of c
+func (c *clause) match(r *record) (bool, error) {
+	for _, cd := range c.conds {
+		v := normalize(r.value(cd.col))
+		ok, err := cd.match(v)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (cd cond) match(v interface{}) (bool, error) {
+	switch cd.op {
+	case "IS NULL":
+		return v == nil, nil
+	case "IS NOT NULL":
+		return v != nil, nil
+	}
+	if v == nil {
+		//comparisons with NULL are never true
+		return false, nil
+	}
+	switch cd.op {
+	case "IN", "NOT IN":
+		for _, w := range cd.vals {
+			if w = normalize(w); w == nil {
+				continue
+			}
+			n, err := compare(v, w)
+			if err != nil {
+				return false, err
+			}
+			if n == 0 {
+				return cd.op == "IN", nil
+			}
+		}
+		return cd.op == "NOT IN", nil
+	case "LIKE", "NOT LIKE":
+		s, ok1 := v.(string)
+		pattern, ok2 := normalize(cd.vals[0]).(string)
+		if !ok1 || !ok2 {
+			return false, fmt.Errorf("LIKE requires strings but got %v and %v", v, cd.vals[0])
+		}
+		return like(pattern).MatchString(s) == (cd.op == "LIKE"), nil
+	}
+	w := normalize(cd.vals[0])
+	if w == nil {
+		return false, nil
+	}
+	n, err := compare(v, w)
+	if err != nil {
+		return false, err
+	}
+	switch cd.op {
+	case "=":
+		return n == 0, nil
+	case "<>":
+		return n != 0, nil
+	case "<":
+		return n < 0, nil
+	case "<=":
+		return n <= 0, nil
+	case ">":
+		return n > 0, nil
+	}
+	return n >= 0, nil
+}
+
+//like compiles SQL LIKE pattern
+func like(pattern string) *regexp.Regexp {
+	var buf strings.Builder
+	buf.WriteString("^(?s)")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.MustCompile(buf.String())
+}
+
+//sortRecords orders rs as per order by, NULL values come first
+func sortRecords(rs []*record, order []dbi.Sort) error {
+	var err error
+	sort.SliceStable(rs, func(i, j int) bool {
+		for _, s := range order {
+			a, b := normalize(rs[i].value(s.Col)), normalize(rs[j].value(s.Col))
+			var n int
+			switch {
+			case a == nil && b == nil:
+			case a == nil:
+				n = -1
+			case b == nil:
+				n = 1
+			default:
+				var cerr error
+				if n, cerr = compare(a, b); cerr != nil && err == nil {
+					err = cerr
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			if s.Desc {
+				return n > 0
+			}
+			return n < 0
+		}
+		return false
+	})
+	return err
+}
+
+//normalize converts v to one of nil, int64, float64, string, bool or time.Time
+func normalize(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		var err error
+		if v, err = valuer.Value(); err != nil {
+			return nil
+		}
+	}
+	switch x := v.(type) {
+	case nil, int64, float64, string, bool, time.Time:
+		return x
+	case []byte:
+		if x == nil {
+			return nil
+		}
+		return string(x)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	}
+	return v
+}
+
+//compare returns -1, 0 or 1 comparing normalized non nil values a and b
+func compare(a, b interface{}) (int, error) {
+	switch x := a.(type) {
+	case int64:
+		switch y := b.(type) {
+		case int64:
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		case float64:
+			return cmpFloat(float64(x), y), nil
+		}
+	case float64:
+		switch y := b.(type) {
+		case int64:
+			return cmpFloat(x, float64(y)), nil
+		case float64:
+			return cmpFloat(x, y), nil
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, nil
+			case y:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		if y, ok := b.(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1, nil
+			case x.After(y):
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("Cannot compare %T with %T", a, b)
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -2,9 +2,8 @@ package dbi
 
 import (
 	"bytes"
+	"database/sql"
 	"errors"
-	"fmt"
-	"io"
 )
 
 //ErrNoPrimaryKey is returned when the model does not have a column marked as PrimaryKey
@@ -16,24 +15,65 @@ func (db *H) Delete(s DBRowMarshaler, optionFunc StmtOption) error {
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return delete(db.DB(), &qc, db.placeholder, db.lw, s)
+	db.startOp(&qc, "Delete", s)
+	err := deleteRow(db.connection(), &qc, db.dbType, db.placeholder, db.queryLogger(), s)
+	return db.endOp(&qc, err)
 }
 
-func delete(conn connection, qc *StmtContext, phMaker func() placeHolderFunc, lw io.Writer, s DBRowMarshaler) error {
+func deleteRow(conn connection, qc *StmtContext, dbType dbTyp, phMaker func() placeHolderFunc, ql QueryLogger, s DBRowMarshaler) error {
+	if err := noPreload(qc); err != nil {
+		return err
+	}
 	row := s.DBRow()
-	phFunc := phMaker()
-	pkey := getPKFromColumns(row)
-	if pkey == nil {
-		return ErrNoPrimaryKey
+	t, err := sqlFor(deleteOp, dbType, phMaker, s, row)
+	if err != nil {
+		return err
+	}
+	pkVal := row[t.pk].Val
+	ev := newQueryEvent(ql, qc, "Delete", s, t.query, []interface{}{pkVal}, t.names)
+	res, err := conn.ExecContext(qc.context, t.query, pkVal)
+	err = logExec(ql, ev, res, err)
+	return err
+}
+
+//DeleteWhere deletes all rows of the model's table matching where and returns the number of deleted rows.
+//The where clause supports the same named arguments as Select e.g. "WHERE expires < @now"
+func (db *H) DeleteWhere(s DBNamer, optionFunc StmtOption, where string, args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	db.startOp(&qc, "DeleteWhere", s)
+	n, err := deleteWhere(db.connection(), &qc, db.placeholder, db.syntax, db.queryLogger(), s, where, args...)
+	return n, db.endOp(&qc, err)
+}
+
+func deleteWhere(
+	conn connection,
+	qc *StmtContext,
+	phMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	s DBNamer,
+	where string,
+	args ...sql.NamedArg) (int64, error) {
+	if err := noPreload(qc); err != nil {
+		return 0, err
 	}
 	var buf bytes.Buffer
 	buf.WriteString("DELETE FROM ")
 	buf.WriteString(s.DBName())
-	buf.WriteString(" WHERE ")
-	buf.WriteString(pkey.Name)
-	buf.WriteString("=")
-	buf.WriteString(phFunc())
-	fmt.Fprintln(lw, buf.String(), pkey.Val)
-	_, err := conn.ExecContext(qc.context, buf.String(), pkey.Val)
-	return err
+	buf.WriteString(" ")
+	buf.WriteString(where)
+	query, qargs, names, err := translateQuery(syntax, phMaker(), buf.String(), args, qc.argSource)
+	if err != nil {
+		return 0, err
+	}
+	ev := newQueryEvent(ql, qc, "DeleteWhere", s, query, qargs, names)
+	res, err := conn.ExecContext(qc.context, query, qargs...)
+	err = logExec(ql, ev, res, err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
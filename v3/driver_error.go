@@ -0,0 +1,298 @@
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+//ErrorKind classifies errors returned by database drivers independently of the driver,
+//it is meant to be used as target of errors.Is e.g. errors.Is(err, dbi.UniqueViolation)
+type ErrorKind int
+
+const (
+	//UniqueViolation means a unique or primary key constraint was violated
+	UniqueViolation ErrorKind = iota + 1
+	//ForeignKeyViolation means a foreign key constraint was violated
+	ForeignKeyViolation
+	//NotNullViolation means NULL was stored in a NOT NULL column
+	NotNullViolation
+	//CheckViolation means a CHECK constraint was violated
+	CheckViolation
+	//Deadlock means the statement was aborted to resolve a deadlock
+	Deadlock
+	//SerializationFailure means a transaction could not be serialized and should be retried
+	SerializationFailure
+	//LockTimeout means a lock could not be acquired in time
+	LockTimeout
+	//ConnectionLost means the connection to the database was lost or is unusable
+	ConnectionLost
+)
+
+var errorKindNames = map[ErrorKind]string{
+	UniqueViolation:      "unique violation",
+	ForeignKeyViolation:  "foreign key violation",
+	NotNullViolation:     "not null violation",
+	CheckViolation:       "check violation",
+	Deadlock:             "deadlock",
+	SerializationFailure: "serialization failure",
+	LockTimeout:          "lock timeout",
+	ConnectionLost:       "connection lost",
+}
+
+func (k ErrorKind) Error() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return "unknown error kind"
+}
+
+//DriverError is an error returned by the database driver classified as one of ErrorKind.
+//errors.Is(err, kind) reports whether it is of the kind and errors.As gives access to the driver error.
+type DriverError struct {
+	Kind       ErrorKind
+	Table      string // table the error relates to if known
+	Constraint string // name of the violated constraint or index if known
+	SQL        string // statement which failed
+	Err        error  // error returned by the driver
+}
+
+func (e *DriverError) Error() string {
+	return e.Err.Error()
+}
+
+//Unwrap returns the error returned by the driver
+func (e *DriverError) Unwrap() error {
+	return e.Err
+}
+
+//Is reports whether target is the kind of e
+func (e *DriverError) Is(target error) bool {
+	k, ok := target.(ErrorKind)
+	return ok && k == e.Kind
+}
+
+//classifyError returns err wrapped in DriverError when it is a driver error of known kind, otherwise err
+func classifyError(err error, query string) error {
+	if err == nil {
+		return nil
+	}
+	var de *DriverError
+	if errors.As(err, &de) {
+		return err
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if found := driverError(e); found != nil {
+			found.SQL = query
+			found.Err = err
+			return found
+		}
+	}
+	if connectionLost(err) {
+		return &DriverError{Kind: ConnectionLost, SQL: query, Err: err}
+	}
+	return err
+}
+
+//driverError classifies error types of lib/pq, pgx, go-sql-driver/mysql and go-sqlite3.
+//The types are recognized by their fields so that dbi does not depend on any driver.
+func driverError(err error) *DriverError {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	switch {
+	case hasField(v, "Severity") && hasField(v, "Code"):
+		return postgresError(v)
+	case hasField(v, "Number") && hasField(v, "Message"):
+		return mysqlError(v, err.Error())
+	case hasField(v, "ExtendedCode") && hasField(v, "Code"):
+		return sqliteError(v, err.Error())
+	}
+	return nil
+}
+
+func hasField(v reflect.Value, name string) bool {
+	return v.FieldByName(name).IsValid()
+}
+
+//stringField returns value of the first of string fields names present in v
+func stringField(v reflect.Value, names ...string) string {
+	for _, name := range names {
+		if f := v.FieldByName(name); f.IsValid() && f.Kind() == reflect.String {
+			return f.String()
+		}
+	}
+	return ""
+}
+
+//intField returns value of integer field name of v
+func intField(v reflect.Value, name string) int64 {
+	f := v.FieldByName(name)
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint())
+	}
+	return 0
+}
+
+//postgresKinds maps SQLSTATE codes to ErrorKind
+var postgresKinds = map[string]ErrorKind{
+	"23505": UniqueViolation,
+	"23503": ForeignKeyViolation,
+	"23502": NotNullViolation,
+	"23514": CheckViolation,
+	"40P01": Deadlock,
+	"40001": SerializationFailure,
+	"55P03": LockTimeout,
+	"57P01": ConnectionLost,
+	"57P02": ConnectionLost,
+	"57P03": ConnectionLost,
+}
+
+//postgresError classifies *pq.Error and pgx.PgError
+func postgresError(v reflect.Value) *DriverError {
+	code := stringField(v, "Code")
+	kind, ok := postgresKinds[code]
+	if !ok && strings.HasPrefix(code, "08") {
+		kind, ok = ConnectionLost, true
+	}
+	if !ok {
+		return nil
+	}
+	return &DriverError{
+		Kind:       kind,
+		Table:      stringField(v, "Table", "TableName"),
+		Constraint: stringField(v, "Constraint", "ConstraintName"),
+	}
+}
+
+//mysqlKinds maps MySQL error numbers to ErrorKind
+var mysqlKinds = map[int64]ErrorKind{
+	1062: UniqueViolation,
+	1586: UniqueViolation,
+	1216: ForeignKeyViolation,
+	1217: ForeignKeyViolation,
+	1451: ForeignKeyViolation,
+	1452: ForeignKeyViolation,
+	1048: NotNullViolation,
+	3819: CheckViolation,
+	1213: Deadlock,
+	1205: LockTimeout,
+	1053: ConnectionLost,
+	2006: ConnectionLost,
+	2013: ConnectionLost,
+}
+
+var (
+	mysqlDuplicateKey = regexp.MustCompile("for key '([^']+)'")
+	mysqlForeignKey   = regexp.MustCompile("`([^`]+)`, CONSTRAINT `([^`]+)`")
+	mysqlCheck        = regexp.MustCompile("Check constraint '([^']+)'")
+)
+
+//mysqlError classifies *mysql.MySQLError, table and constraint are taken from the message when present
+func mysqlError(v reflect.Value, msg string) *DriverError {
+	kind, ok := mysqlKinds[intField(v, "Number")]
+	if !ok {
+		return nil
+	}
+	de := &DriverError{Kind: kind}
+	switch kind {
+	case UniqueViolation:
+		if m := mysqlDuplicateKey.FindStringSubmatch(msg); m != nil {
+			//MySQL 8 reports the key as table.key
+			if i := strings.LastIndex(m[1], "."); i >= 0 {
+				de.Table, de.Constraint = m[1][:i], m[1][i+1:]
+			} else {
+				de.Constraint = m[1]
+			}
+		}
+	case ForeignKeyViolation:
+		if m := mysqlForeignKey.FindStringSubmatch(msg); m != nil {
+			de.Table, de.Constraint = m[1], m[2]
+		}
+	case CheckViolation:
+		if m := mysqlCheck.FindStringSubmatch(msg); m != nil {
+			de.Constraint = m[1]
+		}
+	}
+	return de
+}
+
+//sqlite extended result codes of constraint violations
+var sqliteKinds = map[int64]ErrorKind{
+	2067: UniqueViolation,     // SQLITE_CONSTRAINT_UNIQUE
+	1555: UniqueViolation,     // SQLITE_CONSTRAINT_PRIMARYKEY
+	787:  ForeignKeyViolation, // SQLITE_CONSTRAINT_FOREIGNKEY
+	1299: NotNullViolation,    // SQLITE_CONSTRAINT_NOTNULL
+	275:  CheckViolation,      // SQLITE_CONSTRAINT_CHECK
+}
+
+//sqlite primary result codes
+const (
+	sqliteBusy   = 5
+	sqliteLocked = 6
+)
+
+//sqliteError classifies sqlite3.Error, the table is taken from messages such as
+//UNIQUE constraint failed: person.email and the constraint from CHECK constraint failed: name
+func sqliteError(v reflect.Value, msg string) *DriverError {
+	kind, ok := sqliteKinds[intField(v, "ExtendedCode")]
+	if !ok {
+		switch intField(v, "Code") {
+		case sqliteBusy, sqliteLocked:
+			return &DriverError{Kind: LockTimeout}
+		}
+		return nil
+	}
+	de := &DriverError{Kind: kind}
+	i := strings.Index(msg, "constraint failed: ")
+	if i < 0 {
+		return de
+	}
+	detail := msg[i+len("constraint failed: "):]
+	switch de.Kind {
+	case UniqueViolation, NotNullViolation:
+		if j := strings.Index(detail, "."); j > 0 {
+			de.Table = detail[:j]
+		}
+	case CheckViolation:
+		de.Constraint = detail
+	}
+	return de
+}
+
+//errInvalidConn is the message of mysql.ErrInvalidConn
+const errInvalidConn = "invalid connection"
+
+//connectionLost reports whether err means the connection to the database is gone.
+//Canceled and timed out contexts are not, context.DeadlineExceeded would match net.Error otherwise,
+//and io.EOF only counts when returned by the driver as is rather than wrapped e.g. by a Scanner.
+func connectionLost(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	return err.Error() == errInvalidConn
+}
@@ -0,0 +1,121 @@
+package dbi
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+)
+
+//pqError has the fields of *pq.Error used for classification
+type pqError struct {
+	Severity   string
+	Code       string
+	Message    string
+	Table      string
+	Constraint string
+}
+
+func (e *pqError) Error() string { return "pq: " + e.Message }
+
+//pgxError has the fields of pgx.PgError used for classification
+type pgxError struct {
+	Severity       string
+	Code           string
+	Message        string
+	TableName      string
+	ConstraintName string
+}
+
+func (e pgxError) Error() string { return e.Severity + ": " + e.Message }
+
+//mysqlErr has the fields of *mysql.MySQLError
+type mysqlErr struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlErr) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+//sqliteErr has the fields of sqlite3.Error
+type sqliteErr struct {
+	Code         int
+	ExtendedCode int
+	msg          string
+}
+
+func (e sqliteErr) Error() string { return e.msg }
+
+func TestClassifyError(t *testing.T) {
+	const query = "INSERT INTO person(first) VALUES (?)"
+	cases := []struct {
+		err        error
+		kind       ErrorKind
+		table      string
+		constraint string
+	}{
+		{&pqError{Severity: "ERROR", Code: "23505", Table: "person", Constraint: "person_pkey"}, UniqueViolation, "person", "person_pkey"},
+		{&pqError{Severity: "ERROR", Code: "23503", Table: "report", Constraint: "report_company_fk"}, ForeignKeyViolation, "report", "report_company_fk"},
+		{&pqError{Severity: "FATAL", Code: "08006"}, ConnectionLost, "", ""},
+		{pgxError{Severity: "ERROR", Code: "23502", TableName: "person"}, NotNullViolation, "person", ""},
+		{pgxError{Severity: "ERROR", Code: "23514", TableName: "person", ConstraintName: "age_check"}, CheckViolation, "person", "age_check"},
+		{pgxError{Severity: "ERROR", Code: "40P01"}, Deadlock, "", ""},
+		{pgxError{Severity: "ERROR", Code: "40001"}, SerializationFailure, "", ""},
+		{pgxError{Severity: "ERROR", Code: "55P03"}, LockTimeout, "", ""},
+		{&mysqlErr{1062, "Duplicate entry 'x' for key 'email'"}, UniqueViolation, "", "email"},
+		{&mysqlErr{1062, "Duplicate entry 'x' for key 'person.email'"}, UniqueViolation, "person", "email"},
+		{&mysqlErr{1452, "Cannot add or update a child row: a foreign key constraint fails (`test`.`report`, CONSTRAINT `report_company_fk` FOREIGN KEY (`company_id`) REFERENCES `company` (`id`))"}, ForeignKeyViolation, "report", "report_company_fk"},
+		{&mysqlErr{1048, "Column 'first' cannot be null"}, NotNullViolation, "", ""},
+		{&mysqlErr{3819, "Check constraint 'age_check' is violated."}, CheckViolation, "", "age_check"},
+		{&mysqlErr{1213, "Deadlock found when trying to get lock"}, Deadlock, "", ""},
+		{&mysqlErr{1205, "Lock wait timeout exceeded"}, LockTimeout, "", ""},
+		{sqliteErr{19, 2067, "UNIQUE constraint failed: person.email"}, UniqueViolation, "person", ""},
+		{sqliteErr{19, 1555, "UNIQUE constraint failed: person.id"}, UniqueViolation, "person", ""},
+		{sqliteErr{19, 787, "FOREIGN KEY constraint failed"}, ForeignKeyViolation, "", ""},
+		{sqliteErr{19, 1299, "NOT NULL constraint failed: person.first"}, NotNullViolation, "person", ""},
+		{sqliteErr{19, 275, "CHECK constraint failed: age_check"}, CheckViolation, "", "age_check"},
+		{sqliteErr{5, 5, "database is locked"}, LockTimeout, "", ""},
+		{driver.ErrBadConn, ConnectionLost, "", ""},
+		{io.EOF, ConnectionLost, "", ""},
+		{&net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, ConnectionLost, "", ""},
+		{fmt.Errorf("insert: %w", sqliteErr{19, 2067, "UNIQUE constraint failed: person.email"}), UniqueViolation, "person", ""},
+	}
+	for _, c := range cases {
+		err := classifyError(c.err, query)
+		var de *DriverError
+		if !errors.As(err, &de) {
+			t.Errorf("%v was not classified", c.err)
+			continue
+		}
+		if !errors.Is(err, c.kind) || de.Table != c.table || de.Constraint != c.constraint || de.SQL != query {
+			t.Errorf("%v classified as %+v", c.err, de)
+		}
+		if err.Error() != c.err.Error() || !errors.Is(err, c.err) {
+			t.Errorf("%v does not preserve driver error", err)
+		}
+		if classifyError(err, "other") != err {
+			t.Errorf("%v was classified twice", err)
+		}
+	}
+	for _, err := range []error{
+		nil,
+		ErrNotFound,
+		&pqError{Severity: "ERROR", Code: "42P01"},
+		&mysqlErr{1146, "Table 'test.x' doesn't exist"},
+		sqliteErr{1, 1, "no such table: x"},
+		context.Canceled,
+		context.DeadlineExceeded,
+		fmt.Errorf("query: %w", context.DeadlineExceeded),
+		fmt.Errorf("scan: %w", io.EOF),
+	} {
+		if got := classifyError(err, query); got != err {
+			t.Errorf("%v classified as %v", err, got)
+		}
+	}
+	if errors.Is(classifyError(&mysqlErr{1062, ""}, query), ForeignKeyViolation) {
+		t.Error("unique violation matched foreign key violation")
+	}
+}
@@ -0,0 +1,71 @@
+package dbi
+
+import (
+	"database/sql"
+)
+
+//Exec executes an arbitrary SQL statement that uses named arguments e.g. "UPDATE person SET last = @last"
+//the named arguments are translated to placeholders of the configured database
+func (db *H) Exec(optionFunc StmtOption, query string, args ...sql.NamedArg) (sql.Result, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return nil, err
+	}
+	db.startOp(&qc, "Exec", nil)
+	res, err := execQuery(db.connection(), &qc, db.placeholder, db.syntax, db.queryLogger(), query, args...)
+	return res, db.endOp(&qc, err)
+}
+
+//QueryRaw runs an arbitrary SQL query that uses named arguments and returns the resulting sql.Rows
+//it is the responsibility of the caller to close the returned rows
+func (db *H) QueryRaw(optionFunc StmtOption, query string, args ...sql.NamedArg) (*sql.Rows, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return nil, err
+	}
+	db.startOp(&qc, "QueryRaw", nil)
+	rows, err := rawQuery(db.connection(), &qc, db.placeholder, db.syntax, db.queryLogger(), query, args...)
+	return rows, db.endOp(&qc, err)
+}
+
+func execQuery(
+	conn connection,
+	qc *StmtContext,
+	phMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	query string,
+	args ...sql.NamedArg) (sql.Result, error) {
+	if err := noPreload(qc); err != nil {
+		return nil, err
+	}
+	query, qargs, names, err := translateQuery(syntax, phMaker(), query, args, qc.argSource)
+	if err != nil {
+		return nil, err
+	}
+	ev := newQueryEvent(ql, qc, "Exec", nil, query, qargs, names)
+	res, err := conn.ExecContext(qc.context, query, qargs...)
+	err = logExec(ql, ev, res, err)
+	return res, err
+}
+
+func rawQuery(
+	conn connection,
+	qc *StmtContext,
+	phMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	query string,
+	args ...sql.NamedArg) (*sql.Rows, error) {
+	if err := noPreload(qc); err != nil {
+		return nil, err
+	}
+	query, qargs, names, err := translateQuery(syntax, phMaker(), query, args, qc.argSource)
+	if err != nil {
+		return nil, err
+	}
+	ev := newQueryEvent(ql, qc, "QueryRaw", nil, query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	err = logQuery(ql, ev, err)
+	return rows, err
+}
@@ -1,12 +1,9 @@
 package dbi
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-	"io"
 )
 
 //ErrNotFound returned when the row with the given primary key was not found
@@ -31,34 +28,29 @@ func (db *H) Get(s DBRowUnmarshaler, optionFunc StmtOption) error {
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return get(db.DB(), &qc, db.placeholder, db.lw, s)
+	db.startOp(&qc, "Get", s)
+	err := get(db.connection(), &qc, db.dbType, db.placeholder, db.queryLogger(), s)
+	return db.endOp(&qc, err)
 }
 
 //Get a record from SQL using the supplied PrimaryKey
-func get(conn connection, qc *StmtContext, phMaker func() placeHolderFunc, lw io.Writer, s DBRowUnmarshaler) error {
-	phFunc := phMaker()
+func get(conn connection, qc *StmtContext, dbType dbTyp, phMaker func() placeHolderFunc, ql QueryLogger, s DBRowUnmarshaler) error {
+	if err := noPreload(qc); err != nil {
+		return err
+	}
 	row := s.DBRow()
-	pkey := getPKFromColumns(row)
-	if pkey == nil {
-		return ErrNoPrimaryKey
+	t, err := sqlFor(getOp, dbType, phMaker, s, row)
+	if err != nil {
+		return err
 	}
-	var buf bytes.Buffer
-	buf.WriteString("SELECT ")
-	for i, v := range row {
-		if i > 0 {
-			buf.WriteString(",")
-		}
-		buf.WriteString(v.Name)
+	pkVal := row[t.pk].Val
+	ev := newQueryEvent(ql, qc, "Get", s, t.query, []interface{}{pkVal}, t.names)
+	dbrow := conn.QueryRowContext(qc.context, t.query, pkVal)
+	err = s.DBScan(dbrow)
+	if err == nil {
+		ev.RowsScanned = 1
 	}
-	buf.WriteString(" FROM ")
-	buf.WriteString(s.DBName())
-	buf.WriteString(" WHERE ")
-	buf.WriteString(pkey.Name)
-	buf.WriteString("=")
-	buf.WriteString(phFunc())
-	fmt.Fprintln(lw, buf.String(), pkey.Val)
-	dbrow := conn.QueryRowContext(qc.context, buf.String(), pkey.Val)
-	err := s.DBScan(dbrow)
+	err = logQuery(ql, ev, err)
 	if err == sql.ErrNoRows {
 		return ErrNotFound
 	}
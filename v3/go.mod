@@ -6,4 +6,5 @@ require (
 	github.com/lib/pq v1.0.0
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/pkg/errors v0.8.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -3,8 +3,6 @@ package dbi
 import (
 	"bytes"
 	"database/sql"
-	"fmt"
-	"io"
 )
 
 //Insert a record into sql and return a Col with the primary key and any error
@@ -13,56 +11,43 @@ func (db *H) Insert(s DBRowMarshaler, optionFunc StmtOption) (Col, error) {
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return Col{}, err
 	}
-	return insert(db.conn, &qc, db.dbType, db.placeholder, db.lw, s)
+	db.startOp(&qc, "Insert", s)
+	col, err := insert(db.connection(), &qc, db.dbType, db.placeholder, db.queryLogger(), s)
+	return col, db.endOp(&qc, err)
 }
 
-func insert(conn connection, qc *StmtContext, dbType dbTyp, phMaker func() placeHolderFunc, lw io.Writer, s DBRowMarshaler) (Col, error) {
-	var (
-		buf   bytes.Buffer
-		retPK Col
-	)
-	phFunc := phMaker()
-	buf.WriteString("INSERT INTO ")
-	buf.WriteString(s.DBName())
-	buf.WriteString("(")
-	row := s.DBRow()
-	args := make([]interface{}, 0, len(row))
-	for _, v := range row {
-		if v.skipOnInsert() {
-			continue
-		}
-		if len(args) > 0 {
-			buf.WriteString(",")
-		}
-		buf.WriteString(v.Name)
-		args = append(args, v.Val)
+func insert(conn connection, qc *StmtContext, dbType dbTyp, phMaker func() placeHolderFunc, ql QueryLogger, s DBRowMarshaler) (Col, error) {
+	if err := noPreload(qc); err != nil {
+		return Col{}, err
 	}
-	buf.WriteString(")  VALUES (")
-	for i := 0; i < len(args); i++ {
-		if i > 0 {
-			buf.WriteString(",")
-		}
-		buf.WriteString(phFunc())
+	var retPK Col
+	if err := Validate(qc.context, s); err != nil {
+		return retPK, err
+	}
+	row := s.DBRow()
+	t, err := sqlFor(insertOp, dbType, phMaker, s, row)
+	if err != nil {
+		return retPK, err
 	}
-	buf.WriteString(")")
-	sql := buf.String()
+	args := t.values(row)
 	if dbType == postgres {
 		//postgres inserts should use returning
-		return postgresInsert(conn, qc, s, lw, sql, args)
+		return postgresInsert(conn, qc, s, row, t, ql, args)
 	}
-	fmt.Fprintln(lw, sql, args)
-	result, err := conn.ExecContext(qc.context, sql, args...)
+	ev := newQueryEvent(ql, qc, "Insert", s, t.query, args, t.names)
+	result, err := conn.ExecContext(qc.context, t.query, args...)
+	err = logExec(ql, ev, result, err)
 	if err != nil {
 		return retPK, err
 	}
-	retPK, err = lastInsertPKID(conn, qc, phMaker, lw, s, result)
+	retPK, err = lastInsertPKID(conn, qc, phMaker, ql, s, result)
 	if err != nil {
 		return retPK, err
 	}
 	return retPK, err
 }
 
-func lastInsertPKID(tx connection, qc *StmtContext, phMaker func() placeHolderFunc, lw io.Writer, s DBRowMarshaler, result sql.Result) (Col, error) {
+func lastInsertPKID(tx connection, qc *StmtContext, phMaker func() placeHolderFunc, ql QueryLogger, s DBRowMarshaler, result sql.Result) (Col, error) {
 	var (
 		buf   bytes.Buffer
 		retPK Col
@@ -94,6 +79,7 @@ func lastInsertPKID(tx connection, qc *StmtContext, phMaker func() placeHolderFu
 	buf.WriteString(s.DBName())
 	buf.WriteString(" WHERE ")
 	args := make([]interface{}, 0, len(row))
+	names := make([]string, 0, len(row))
 	for _, v := range row {
 		if v.isPrimaryKey() || v.skipOnInsert() || v.isBinaryBlob() {
 			continue
@@ -105,12 +91,14 @@ func lastInsertPKID(tx connection, qc *StmtContext, phMaker func() placeHolderFu
 		buf.WriteString("=")
 		buf.WriteString(phFunc())
 		args = append(args, v.Val)
+		names = append(names, v.Name)
 	}
 	buf.WriteString(" ORDER BY ")
 	buf.WriteString(pk.Name)
 	buf.WriteString(" DESC ") //presumably order by highest first
-	fmt.Fprintln(lw, buf.String(), args)
+	ev := newQueryEvent(ql, qc, "Insert", s, buf.String(), args, names)
 	rows, err := tx.QueryContext(qc.context, buf.String(), args...)
+	err = logQuery(ql, ev, err)
 	if err != nil {
 		return retPK, err
 	}
@@ -121,31 +109,30 @@ func lastInsertPKID(tx connection, qc *StmtContext, phMaker func() placeHolderFu
 	return retPK, err
 }
 
-func postgresInsert(conn connection, qc *StmtContext, s DBRowMarshaler, lw io.Writer, sql string, args []interface{}) (Col, error) {
-	plainInsert := false
-	//first let's make sure this even has a primary key
-	row := s.DBRow()
-	pk := getPKFromColumns(row)
-	if pk == nil {
-		plainInsert = true
-	}
-
-	if plainInsert {
-		fmt.Fprintln(lw, sql, args)
-		_, err := conn.ExecContext(qc.context, sql, args...)
+func postgresInsert(conn connection, qc *StmtContext, s DBRowMarshaler, row []Col, t *sqlTemplate, ql QueryLogger, args []interface{}) (Col, error) {
+	//plain insert without a primary key
+	if t.pk < 0 {
+		ev := newQueryEvent(ql, qc, "Insert", s, t.query, args, t.names)
+		res, err := conn.ExecContext(qc.context, t.query, args...)
+		err = logExec(ql, ev, res, err)
 		return Col{}, err
 	}
 
-	//turn into returning query
-	sql = fmt.Sprintf("%s RETURNING %s", sql, pk.Name)
-	fmt.Fprintln(lw, sql, args)
+	pk := row[t.pk]
+	ev := newQueryEvent(ql, qc, "Insert", s, t.returning, args, t.names)
 	var liid int64
-	if err := conn.QueryRowContext(qc.context, sql, args...).Scan(&liid); err != nil {
-		return *pk, err
+	err := conn.QueryRowContext(qc.context, t.returning, args...).Scan(&liid)
+	if err == nil {
+		ev.RowsAffected = 1
+		ev.RowsScanned = 1
+	}
+	err = logQuery(ql, ev, err)
+	if err != nil {
+		return pk, err
 	}
-	cnvtLiid, err := forceToTypeOfVal(pk, liid)
+	cnvtLiid, err := forceToTypeOfVal(&pk, liid)
 	if err == nil {
 		pk.Val = cnvtLiid
 	}
-	return *pk, err
+	return pk, err
 }
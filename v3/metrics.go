@@ -0,0 +1,209 @@
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Observation describes a finished dbi operation passed to Metrics
+type Observation struct {
+	Operation    string        // dbi operation e.g. Insert, Get, Select, Begin, Commit
+	Table        string        // table of the model, empty for arbitrary SQL such as Exec
+	Duration     time.Duration // how long the whole operation took
+	RowsScanned  int64         // rows read from results of queries of the operation
+	RowsAffected int64         // rows affected by statements of the operation
+	Err          error         // error returned by the operation if any
+	ErrClass     string        // class of Err e.g. not_found, unique_violation, empty when Err is nil
+}
+
+//Metrics receives an observation for every dbi operation
+type Metrics interface {
+	Observe(o *Observation)
+}
+
+//CollectMetrics is an optional configuration option which reports every dbi operation to m
+//db, err := New(mySqlConn, CollectMetrics(NewMemoryMetrics()))
+func CollectMetrics(m Metrics) DBOption {
+	return func(db *H) error {
+		if m == nil {
+			return errors.New("metrics collector is nil")
+		}
+		db.metrics = m
+		return nil
+	}
+}
+
+//errorClass returns a short low cardinality description of err suitable as metric label
+func errorClass(err error) string {
+	var (
+		verr *ValidationError
+		derr *DriverError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound), errors.Is(err, sql.ErrNoRows):
+		return "not_found"
+	case errors.As(err, &verr):
+		return "validation"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &derr):
+		return strings.Replace(derr.Kind.Error(), " ", "_", -1)
+	}
+	return "other"
+}
+
+//DefaultLatencyBuckets are the upper bounds of latency histogram buckets used by NewMemoryMetrics by default
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+//Histogram counts observed durations in buckets
+type Histogram struct {
+	Bounds []time.Duration // upper bounds (inclusive) of buckets in ascending order
+	Counts []int64         // observations per bucket, the last one counts durations above all bounds
+	Count  int64           // number of observations
+	Sum    time.Duration   // sum of observed durations
+	Min    time.Duration   // shortest observed duration
+	Max    time.Duration   // longest observed duration
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+	if d > h.Max {
+		h.Max = d
+	}
+	h.Count++
+	h.Sum += d
+}
+
+//Mean returns the average observed duration
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+//OpStats are the metrics of one operation on one table
+type OpStats struct {
+	Operation    string
+	Table        string
+	Calls        int64            // number of times the operation was called
+	Errors       map[string]int64 // failed calls by error class e.g. not_found, validation, other
+	Latency      Histogram        // duration of the operation
+	RowsScanned  int64            // rows read in total
+	RowsAffected int64            // rows affected in total
+}
+
+type opKey struct {
+	op    string
+	table string
+}
+
+//MemoryMetrics is Metrics keeping counters and histograms per operation and table in memory
+type MemoryMetrics struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	stats   map[opKey]*OpStats
+}
+
+//NewMemoryMetrics returns MemoryMetrics with latency histograms using buckets as upper bounds,
+//DefaultLatencyBuckets are used when none are given
+func NewMemoryMetrics(buckets ...time.Duration) *MemoryMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := make([]time.Duration, len(buckets))
+	copy(b, buckets)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return &MemoryMetrics{buckets: b, stats: make(map[opKey]*OpStats)}
+}
+
+//Observe records o
+func (m *MemoryMetrics) Observe(o *Observation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := opKey{op: o.Operation, table: o.Table}
+	s, ok := m.stats[k]
+	if !ok {
+		s = &OpStats{
+			Operation: o.Operation,
+			Table:     o.Table,
+			Errors:    make(map[string]int64),
+			Latency:   Histogram{Bounds: m.buckets, Counts: make([]int64, len(m.buckets)+1)},
+		}
+		m.stats[k] = s
+	}
+	s.Calls++
+	if o.Err != nil {
+		class := o.ErrClass
+		if class == "" {
+			class = errorClass(o.Err)
+		}
+		s.Errors[class]++
+	}
+	s.Latency.observe(o.Duration)
+	s.RowsScanned += o.RowsScanned
+	s.RowsAffected += o.RowsAffected
+}
+
+//Snapshot returns copy of current metrics sorted by operation and table
+func (m *MemoryMetrics) Snapshot() []OpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	res := make([]OpStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		c := *s
+		c.Errors = make(map[string]int64, len(s.Errors))
+		for k, v := range s.Errors {
+			c.Errors[k] = v
+		}
+		c.Latency.Counts = make([]int64, len(s.Latency.Counts))
+		copy(c.Latency.Counts, s.Latency.Counts)
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Operation != res[j].Operation {
+			return res[i].Operation < res[j].Operation
+		}
+		return res[i].Table < res[j].Table
+	})
+	return res
+}
+
+//Stats returns metrics of operation op on table, nil when it was not observed yet
+func (m *MemoryMetrics) Stats(op, table string) *OpStats {
+	for _, s := range m.Snapshot() {
+		if s.Operation == op && s.Table == table {
+			return &s
+		}
+	}
+	return nil
+}
+
+//Reset discards all recorded metrics
+func (m *MemoryMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = make(map[opKey]*OpStats)
+}
@@ -0,0 +1,62 @@
+package dbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryMetrics(t *testing.T) {
+	mm := NewMemoryMetrics(10*time.Millisecond, time.Millisecond)
+	for _, d := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 20 * time.Millisecond} {
+		mm.Observe(&Observation{Operation: "Get", Table: "person", Duration: d, RowsScanned: 1})
+	}
+	mm.Observe(&Observation{Operation: "Get", Table: "person", Duration: 3 * time.Millisecond, Err: ErrNotFound})
+	mm.Observe(&Observation{Operation: "Delete", Table: "person", RowsAffected: 1, Err: errors.New("boom")})
+	snap := mm.Snapshot()
+	if len(snap) != 2 || snap[0].Operation != "Delete" || snap[1].Operation != "Get" {
+		t.Fatalf("unexpected snapshot %+v", snap)
+	}
+	get := snap[1]
+	if get.Calls != 4 || get.RowsScanned != 3 || get.Errors["not_found"] != 1 {
+		t.Fatalf("unexpected stats %+v", get)
+	}
+	h := get.Latency
+	if fmt.Sprint(h.Counts) != "[1 2 1]" || h.Count != 4 || h.Min != time.Millisecond || h.Max != 20*time.Millisecond {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	if h.Mean() != 26*time.Millisecond/4 {
+		t.Fatalf("unexpected mean %s", h.Mean())
+	}
+	if snap[0].Errors["other"] != 1 || snap[0].RowsAffected != 1 {
+		t.Fatalf("unexpected stats %+v", snap[0])
+	}
+	//snapshots are copies
+	snap[1].Errors["other"] = 5
+	snap[1].Latency.Counts[0] = 5
+	if st := mm.Stats("Get", "person"); st.Errors["other"] != 0 || st.Latency.Counts[0] != 1 {
+		t.Fatalf("snapshot shares state with metrics %+v", st)
+	}
+	if mm.Stats("Insert", "person") != nil {
+		t.Fatal("expected no stats for operation which was not observed")
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	cases := map[error]string{
+		nil:                                    "",
+		ErrNotFound:                            "not_found",
+		&ValidationError{}:                     "validation",
+		context.Canceled:                       "canceled",
+		context.DeadlineExceeded:               "timeout",
+		errors.New("syntax"):                   "other",
+		fmt.Errorf("wrapped: %w", ErrNotFound): "not_found",
+	}
+	for err, want := range cases {
+		if got := errorClass(err); got != want {
+			t.Errorf("errorClass(%v) = %q expected %q", err, got, want)
+		}
+	}
+}
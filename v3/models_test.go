@@ -2,9 +2,12 @@ package dbi
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"math/big"
+	"regexp"
 	"time"
 )
 
@@ -17,9 +20,10 @@ func init() {
 }
 
 type Company struct {
-	ID     int64  `json:"id"`
-	Name   string `json:"name"`
-	Ticker string `json:"ticker"`
+	ID      int64           `json:"id"`
+	Name    string          `json:"name"`
+	Ticker  string          `json:"ticker"`
+	Reports []*AnnualReport `json:"-"`
 }
 
 func (c *Company) DBName() string {
@@ -38,12 +42,45 @@ func (c *Company) DBScan(scanner Scanner) error {
 	return scanner.Scan(&c.ID, &c.Name, &c.Ticker)
 }
 
+//stepCompany scans its columns in two steps and rejects rows without a name
+type stepCompany struct {
+	Company
+}
+
+func (c *stepCompany) DBScan(scanner Scanner) error {
+	if err := scanner.Scan(&c.ID); err != nil {
+		return err
+	}
+	if err := scanner.Scan(&c.Name, &c.Ticker); err != nil {
+		return err
+	}
+	if c.Name == "" {
+		return errors.New("company without name")
+	}
+	return nil
+}
+
+func (c *Company) DBRelations() []Relation {
+	return []Relation{
+		HasMany("reports", "ID", "company_id", func() DBRowUnmarshaler { return &AnnualReport{} }),
+	}
+}
+
+func (c *Company) DBSetRelated(name string, related []DBRowUnmarshaler) error {
+	c.Reports = nil
+	for _, v := range related {
+		c.Reports = append(c.Reports, v.(*AnnualReport))
+	}
+	return nil
+}
+
 type AnnualReport struct {
 	ID        int64
 	CompanyID int64
 	Year      int
 	Sales     *big.Int
 	NetIncome *big.Int
+	Company   *Company
 }
 
 func (ar *AnnualReport) DBName() string {
@@ -101,6 +138,20 @@ func (ar *AnnualReport) DBScan(scanner Scanner) error {
 	return nil
 }
 
+func (ar *AnnualReport) DBRelations() []Relation {
+	return []Relation{
+		BelongsTo("company", "company_id", "ID", func() DBRowUnmarshaler { return &Company{} }),
+	}
+}
+
+func (ar *AnnualReport) DBSetRelated(name string, related []DBRowUnmarshaler) error {
+	ar.Company = nil
+	if len(related) > 0 {
+		ar.Company = related[0].(*Company)
+	}
+	return nil
+}
+
 type Person struct {
 	ID        int
 	FirstName string
@@ -126,3 +177,69 @@ func (p *Person) DBRow() []Col {
 func (p *Person) DBScan(scanner Scanner) error {
 	return scanner.Scan(&p.ID, &p.FirstName, &p.LastName)
 }
+
+var emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+$`)
+
+type Subscriber struct {
+	ID    int64
+	Email string
+	Name  string
+	Age   int
+}
+
+func (s *Subscriber) DBName() string {
+	return "subscriber"
+}
+
+func (s *Subscriber) DBRow() []Col {
+	return []Col{
+		Col{"id", s.ID, pkMeta},
+		Col{"email", s.Email, nil},
+		Col{"name", s.Name, &ColOpt{Type: "varchar(10)"}},
+		Col{"age", s.Age, nil},
+	}
+}
+
+func (s *Subscriber) DBScan(scanner Scanner) error {
+	return scanner.Scan(&s.ID, &s.Email, &s.Name, &s.Age)
+}
+
+func (s *Subscriber) DBRules() map[string][]Rule {
+	return map[string][]Rule{
+		"email": {Required(), Match(emailRegexp)},
+		"age":   {Range(0, 150)},
+	}
+}
+
+func (s *Subscriber) Validate(ctx context.Context) error {
+	if s.Name == "root" {
+		return errors.New("name root is reserved")
+	}
+	return nil
+}
+
+var secretMeta = &ColOpt{Flags: Sensitive}
+
+type Account struct {
+	ID       int64
+	Login    string
+	Password string
+	APIToken string
+}
+
+func (a *Account) DBName() string {
+	return "account"
+}
+
+func (a *Account) DBRow() []Col {
+	return []Col{
+		Col{"id", a.ID, pkMeta},
+		Col{"login", a.Login, nil},
+		Col{"password", a.Password, secretMeta},
+		Col{"api_token", a.APIToken, nil},
+	}
+}
+
+func (a *Account) DBScan(scanner Scanner) error {
+	return scanner.Scan(&a.ID, &a.Login, &a.Password, &a.APIToken)
+}
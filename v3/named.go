@@ -4,12 +4,35 @@ import (
 	"bytes"
 	"io"
 	"unicode"
+	"unicode/utf8"
 )
 
+//argSyntax describes how named arguments, quoting and comments look like in queries of the configured database
+type argSyntax struct {
+	prefix           rune // named argument prefix e.g. @ in @name
+	backslashEscapes bool // \ escapes characters inside of quoted strings (MySQL)
+	hashComments     bool // # starts a line comment (MySQL)
+	dollarQuotes     bool // $tag$ ... $tag$ quoted strings and E'' strings (Postgres)
+	nestedComments   bool // block comments can be nested (Postgres)
+	positional       bool // plain positional arguments are allowed
+}
+
 func produceQuery(prefix rune, ph placeHolderFunc, inputQuery string) (string, []string, error) {
+	return produceExpandedQuery(argSyntax{prefix: prefix}, ph, inputQuery, nil)
+}
+
+//produceExpandedQuery is like produceQuery but arity tells how many placeholders
+//each named argument expands to e.g. IN (@ids) becomes IN ($1,$2,$3)
+//zero arity produces NULL, translateQuery rejects empty slices with ErrEmptySlice before the query is run
+//
+//The prefix is only recognized outside of quoted strings, quoted identifiers, comments
+//and dollar quoted strings. A doubled prefix (@@session or ::cast) is left as it is,
+//and the prefix can be escaped with a backslash e.g. \@ becomes @.
+func produceExpandedQuery(syntax argSyntax, ph placeHolderFunc, inputQuery string, arity func(string) int) (string, []string, error) {
 	ctx := parseContext{
-		prefix: prefix,
+		syntax: syntax,
 		ph:     ph,
+		arity:  arity,
 		in:     bytes.NewBufferString(inputQuery),
 		out:    new(bytes.Buffer),
 		argBuf: new(bytes.Buffer),
@@ -21,19 +44,77 @@ func produceQuery(prefix rune, ph placeHolderFunc, inputQuery string) (string, [
 }
 
 type parseContext struct {
-	prefix rune
-	ph     placeHolderFunc
-	in     *bytes.Buffer
-	out    *bytes.Buffer
-	argBuf *bytes.Buffer
-	args   []string
-	err    error
+	syntax     argSyntax
+	ph         placeHolderFunc
+	arity      func(string) int
+	in         *bytes.Buffer
+	out        *bytes.Buffer
+	argBuf     *bytes.Buffer
+	args       []string
+	err        error
+	raw        []byte // bytes of the last read rune
+	last       rune   // last two runes written while in basic state
+	beforeLast rune   // used to recognize E'' strings and $tag$ quotes
+	quote      rune   // the closing quote while in quoted state
+	escapes    bool   // backslash escapes apply in quoted state
+	depth      int    // nesting level of block comments
+	dollarTag  string // the closing tag while in dollar quoted state
+}
+
+//writePlaceHolders writes as many comma separated placeholders as the arity of the named argument
+func (pc *parseContext) writePlaceHolders(name string) {
+	n := 1
+	if pc.arity != nil {
+		n = pc.arity(name)
+	}
+	if n == 0 {
+		pc.out.WriteString("NULL")
+		return
+	}
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			pc.out.WriteRune(',')
+		}
+		pc.out.WriteString(pc.ph())
+	}
+}
+
+//peek returns the next rune without consuming it
+func (pc *parseContext) peek() rune {
+	r, _ := utf8.DecodeRune(pc.in.Bytes())
+	return r
+}
+
+//read consumes the next rune remembering its raw bytes
+//so that invalid UTF-8 is copied to output untouched
+func (pc *parseContext) read() (rune, error) {
+	b := pc.in.Bytes()
+	r, size, err := pc.in.ReadRune()
+	pc.raw = b[:size]
+	return r, err
+}
+
+//copyRaw writes the raw bytes of the last read rune
+func (pc *parseContext) copyRaw() {
+	pc.out.Write(pc.raw)
+}
+
+//skip consumes the next rune and writes it out
+func (pc *parseContext) skip() {
+	if _, err := pc.read(); err == nil {
+		pc.copyRaw()
+	}
+}
+
+func (pc *parseContext) remember(r rune) {
+	pc.beforeLast = pc.last
+	pc.last = r
 }
 
 type stateFn func(*parseContext) stateFn
 
 func basicFn(pc *parseContext) stateFn {
-	r, _, err := pc.in.ReadRune()
+	r, err := pc.read()
 	if err == io.EOF {
 		return nil
 	}
@@ -42,15 +123,53 @@ func basicFn(pc *parseContext) stateFn {
 		return nil
 	}
 
-	if r == pc.prefix {
+	next := pc.peek()
+	switch {
+	case r == '\\' && next == pc.syntax.prefix:
+		//escaped prefix
+		_, _ = pc.read()
+		pc.copyRaw()
+		pc.remember(next)
+		return basicFn
+	case r == pc.syntax.prefix && next == pc.syntax.prefix:
+		//@@session variable or ::cast
+		pc.copyRaw()
+		pc.skip()
+		pc.remember(next)
+		return basicFn
+	case r == pc.syntax.prefix && isStartOfArgumentRune(next):
 		return inArgFn
+	case r == '\'' || r == '"' || r == '`':
+		pc.quote = r
+		pc.escapes = r != '`' && (pc.syntax.backslashEscapes ||
+			(r == '\'' && pc.syntax.dollarQuotes &&
+				(pc.last == 'E' || pc.last == 'e') && !isIdentRune(pc.beforeLast)))
+		pc.copyRaw()
+		return quotedFn
+	case r == '-' && next == '-', r == '#' && pc.syntax.hashComments:
+		pc.copyRaw()
+		return lineCommentFn
+	case r == '/' && next == '*':
+		pc.copyRaw()
+		pc.skip()
+		pc.depth = 1
+		return blockCommentFn
+	case r == '$' && pc.syntax.dollarQuotes && !isIdentRune(pc.last):
+		if tag, ok := dollarTag(pc.in.Bytes()); ok {
+			pc.copyRaw()
+			pc.out.WriteString(tag)
+			pc.in.Next(len(tag))
+			pc.dollarTag = tag
+			return dollarQuotedFn
+		}
 	}
-	pc.out.WriteRune(r)
+	pc.copyRaw()
+	pc.remember(r)
 	return basicFn
 }
 
 func inArgFn(pc *parseContext) stateFn {
-	r, _, err := pc.in.ReadRune()
+	r, err := pc.read()
 	if err == io.EOF {
 		return inArgEOFFn
 	}
@@ -61,28 +180,140 @@ func inArgFn(pc *parseContext) stateFn {
 
 	if isEndOfArgumentRune(r) {
 		//write place holder
-		pc.out.WriteString(pc.ph())
-		//write whatever rune this is to query buffer
-		pc.out.WriteRune(r)
+		pc.writePlaceHolders(pc.argBuf.String())
+		//leave whatever rune this is to the basic state
+		_ = pc.in.UnreadRune()
 		//append the arg
 		pc.args = append(pc.args, pc.argBuf.String())
 		pc.argBuf.Reset()
+		pc.remember('_')
 		//back to basic state
 		return basicFn
 	}
-	pc.argBuf.WriteRune(r)
+	pc.argBuf.Write(pc.raw)
 	return inArgFn
 }
 
 func inArgEOFFn(pc *parseContext) stateFn {
 	//write place holder
-	pc.out.WriteString(pc.ph())
+	pc.writePlaceHolders(pc.argBuf.String())
 	//append the arg
 	pc.args = append(pc.args, pc.argBuf.String())
 	//back to basic state
 	return nil
 }
 
+//quotedFn copies quoted strings and identifiers as they are
+func quotedFn(pc *parseContext) stateFn {
+	r, err := pc.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		pc.err = err
+		return nil
+	}
+	pc.copyRaw()
+	switch {
+	case r == '\\' && pc.escapes:
+		pc.skip()
+	case r == pc.quote:
+		//doubled quote is an escaped quote
+		if pc.peek() == pc.quote {
+			pc.skip()
+			return quotedFn
+		}
+		pc.remember(r)
+		return basicFn
+	}
+	return quotedFn
+}
+
+func lineCommentFn(pc *parseContext) stateFn {
+	r, err := pc.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		pc.err = err
+		return nil
+	}
+	pc.copyRaw()
+	if r == '\n' {
+		pc.remember(r)
+		return basicFn
+	}
+	return lineCommentFn
+}
+
+func blockCommentFn(pc *parseContext) stateFn {
+	r, err := pc.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		pc.err = err
+		return nil
+	}
+	pc.copyRaw()
+	switch next := pc.peek(); {
+	case r == '*' && next == '/':
+		pc.skip()
+		pc.depth--
+		if pc.depth == 0 {
+			pc.remember(' ')
+			return basicFn
+		}
+	case r == '/' && next == '*' && pc.syntax.nestedComments:
+		pc.skip()
+		pc.depth++
+	}
+	return blockCommentFn
+}
+
+func dollarQuotedFn(pc *parseContext) stateFn {
+	r, err := pc.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		pc.err = err
+		return nil
+	}
+	pc.copyRaw()
+	if r == '$' && bytes.HasPrefix(pc.in.Bytes(), []byte(pc.dollarTag)) {
+		pc.out.WriteString(pc.dollarTag)
+		pc.in.Next(len(pc.dollarTag))
+		pc.remember('$')
+		return basicFn
+	}
+	return dollarQuotedFn
+}
+
+//dollarTag returns the rest of the opening $tag$ of a dollar quoted string e.g. tag$ or just $
+//it does not match positional parameters such as $1
+func dollarTag(in []byte) (string, bool) {
+	for i, r := range string(in) {
+		switch {
+		case r == '$':
+			return string(in[:i+1]), true
+		case i == 0 && !isStartOfArgumentRune(r):
+			return "", false
+		case !isIdentRune(r):
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isStartOfArgumentRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
+
 func isEndOfArgumentRune(r rune) bool {
 	if unicode.IsLetter(r) {
 		return false
@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package dbi
+
+import (
+	"strings"
+	"testing"
+)
+
+//FuzzNamedArgs checks that translating named arguments round-trips
+//i.e. replacing the placeholders back with the named arguments gives the original query
+func FuzzNamedArgs(f *testing.F) {
+	for _, seed := range []string{
+		"SELECT ID,Name,Ticker FROM company WHERE Ticker != @ticker AND ID != @id AND ID > @id",
+		"WHERE email = 'a@b.com' AND id = @id",
+		"WHERE name = 'it''s @x' AND id IN (@ids)",
+		`SELECT "col@x", ` + "`col@y`" + `, @a FROM t`,
+		"SELECT @a -- comment @b\n, @c /* @d /* @e */ @f */ @g",
+		"SELECT @@session.sql_mode, @a, @ + 1",
+		"SELECT $$ @a $$, $fn$ '@a' $fn$, $1, @b",
+		`SELECT E'it\'s @a', 'a\', @b # @c` + "\n",
+		"SELECT id::text FROM t WHERE id = :id",
+		"SELECT 'unterminated @a",
+	} {
+		f.Add(seed)
+	}
+	syntaxes := []argSyntax{
+		{prefix: '@'},
+		{prefix: '@', dollarQuotes: true, nestedComments: true},
+		{prefix: '@', backslashEscapes: true, hashComments: true},
+		{prefix: ':', dollarQuotes: true, nestedComments: true},
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		if strings.ContainsRune(in, 0) {
+			return
+		}
+		for _, syntax := range syntaxes {
+			prefix := string(syntax.prefix)
+			if strings.Contains(in, `\`+prefix) {
+				//escaped prefix does not round-trip
+				continue
+			}
+			marker := func() string { return "\x00" }
+			out, args, err := produceExpandedQuery(syntax, marker, in, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := strings.Split(out, "\x00")
+			if len(parts) != len(args)+1 {
+				t.Fatalf("%q: %d placeholders for %d args", in, len(parts)-1, len(args))
+			}
+			var buf strings.Builder
+			for i, part := range parts {
+				if i > 0 {
+					buf.WriteString(prefix)
+					buf.WriteString(args[i-1])
+				}
+				buf.WriteString(part)
+			}
+			if buf.String() != in {
+				t.Fatalf("round-trip mismatch\n%q\n%q", in, buf.String())
+			}
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package dbi
 
 import (
+	"database/sql"
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -67,3 +70,190 @@ func TestNamedArgsParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestNamedArgsExpansion(t *testing.T) {
+	var tests = []struct {
+		in    string
+		args  []sql.NamedArg
+		out   string
+		qargs []interface{}
+	}{
+		{
+			"WHERE id IN (@ids) AND last = @last",
+			[]sql.NamedArg{sql.Named("ids", []int64{3, 4, 5}), sql.Named("last", "Doe")},
+			"WHERE id IN ($1,$2,$3) AND last = $4",
+			[]interface{}{int64(3), int64(4), int64(5), "Doe"},
+		},
+		{
+			"WHERE id IN (@ids) OR last IN (@ids)",
+			[]sql.NamedArg{sql.Named("ids", []string{"a", "b"})},
+			"WHERE id IN ($1,$2) OR last IN ($3,$4)",
+			[]interface{}{"a", "b", "a", "b"},
+		},
+		{
+			"WHERE uuid = @uuid AND id IN (@ids)",
+			[]sql.NamedArg{sql.Named("uuid", [2]byte{1, 2}), sql.Named("ids", [2]int{1, 2})},
+			"WHERE uuid = $1 AND id IN ($2,$3)",
+			[]interface{}{[2]byte{1, 2}, 1, 2},
+		},
+		{
+			"WHERE id = ANY(@ids) AND data = @data",
+			[]sql.NamedArg{sql.Named("ids", Array([]int64{1, 2})), sql.Named("data", []byte("xy"))},
+			"WHERE id = ANY($1) AND data = $2",
+			[]interface{}{[]int64{1, 2}, []byte("xy")},
+		},
+	}
+	for _, test := range tests {
+		query, qargs, _, err := translateQuery(argSyntax{prefix: '@'}, pgPlaceHolder(), test.in, test.args, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if query != test.out {
+			t.Errorf("Expected\n%s\nbut got\n%s", test.out, query)
+		}
+		if !reflect.DeepEqual(qargs, test.qargs) {
+			t.Errorf("Expected %#v but got %#v", test.qargs, qargs)
+		}
+	}
+}
+
+func TestNamedArgsEmptySlice(t *testing.T) {
+	args := []sql.NamedArg{sql.Named("ids", []int{})}
+	_, _, _, err := translateQuery(argSyntax{prefix: '@'}, pgPlaceHolder(), "WHERE id NOT IN (@ids)", args, nil)
+	if !errors.Is(err, ErrEmptySlice) {
+		t.Fatalf("Expected ErrEmptySlice but got %v", err)
+	}
+}
+
+func TestNamedArgsLexer(t *testing.T) {
+	var (
+		plain = argSyntax{prefix: '@'}
+		pg    = argSyntax{prefix: '@', dollarQuotes: true, nestedComments: true}
+		my    = argSyntax{prefix: '@', backslashEscapes: true, hashComments: true}
+	)
+	var tests = []struct {
+		syntax argSyntax
+		in     string
+		out    string
+		args   []string
+	}{
+		{plain, "WHERE email = 'a@b.com' AND id = @id", "WHERE email = 'a@b.com' AND id = $1", []string{"id"}},
+		{plain, "WHERE name = 'it''s @x' AND id = @id", "WHERE name = 'it''s @x' AND id = $1", []string{"id"}},
+		{plain, `SELECT "col@x", @a FROM t`, `SELECT "col@x", $1 FROM t`, []string{"a"}},
+		{plain, "SELECT `col@x`, @a FROM t", "SELECT `col@x`, $1 FROM t", []string{"a"}},
+		{plain, "SELECT @a -- comment @b\n, @c", "SELECT $1 -- comment @b\n, $2", []string{"a", "c"}},
+		{plain, "SELECT /* @b */ @a", "SELECT /* @b */ $1", []string{"a"}},
+		{plain, "SELECT @a/*x*/", "SELECT $1/*x*/", []string{"a"}},
+		{plain, `SELECT \@a, @b`, "SELECT @a, $1", []string{"b"}},
+		{plain, "SELECT @@session.sql_mode, @a", "SELECT @@session.sql_mode, $1", []string{"a"}},
+		{plain, "SELECT @ + 1, @a", "SELECT @ + 1, $1", []string{"a"}},
+		{plain, "SELECT @a,@b", "SELECT $1,$2", []string{"a", "b"}},
+		{plain, "SELECT 'unterminated @a", "SELECT 'unterminated @a", nil},
+		{pg, "SELECT $$ @a $$, @b", "SELECT $$ @a $$, $1", []string{"b"}},
+		{pg, "SELECT $fn$ '@a' $ @x $fn$, @b", "SELECT $fn$ '@a' $ @x $fn$, $1", []string{"b"}},
+		{pg, "SELECT $1, @b", "SELECT $1, $1", []string{"b"}},
+		{pg, `SELECT E'it\'s @a', @b`, `SELECT E'it\'s @a', $1`, []string{"b"}},
+		{pg, `SELECT 'a\', @b`, `SELECT 'a\', $1`, []string{"b"}},
+		{pg, "SELECT /* /* @a */ @b */ @c", "SELECT /* /* @a */ @b */ $1", []string{"c"}},
+		{plain, "SELECT /* /* @a */ @b */ @c", "SELECT /* /* @a */ $1 */ $2", []string{"b", "c"}},
+		{my, `SELECT 'it\'s @a', @b`, `SELECT 'it\'s @a', ?`, []string{"b"}},
+		{my, "SELECT @a # comment @b\n", "SELECT ? # comment @b\n", []string{"a"}},
+		{argSyntax{prefix: ':'}, "SELECT id::text FROM t WHERE id = :id", "SELECT id::text FROM t WHERE id = $1", []string{"id"}},
+	}
+	for _, test := range tests {
+		ph := pgPlaceHolder()
+		if test.syntax.backslashEscapes {
+			ph = defaultPlaceHolder()
+		}
+		query, args, err := produceExpandedQuery(test.syntax, ph, test.in, nil)
+		if err != nil {
+			t.Error(err)
+		}
+		if query != test.out {
+			t.Errorf("Expected\n%s\nbut got\n%s", test.out, query)
+		}
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: expected args %v but got %v", test.in, test.args, args)
+		}
+	}
+}
+
+type personFilter struct {
+	Base
+	LastName string `dbi:"last"`
+	Secret   string `dbi:"-"`
+	internal string
+}
+
+type Base struct {
+	First string
+}
+
+func TestNamedArgsSources(t *testing.T) {
+	var tests = []struct {
+		src   interface{}
+		query string
+		qargs []interface{}
+	}{
+		{
+			map[string]interface{}{"last": "Doe", "ids": []int{1, 2}},
+			"WHERE last = @last AND id IN (@ids)",
+			[]interface{}{"Doe", 1, 2},
+		},
+		{
+			map[string]string{"last": "Doe"},
+			"WHERE last = @last",
+			[]interface{}{"Doe"},
+		},
+		{
+			&personFilter{Base: Base{First: "John"}, LastName: "Doe"},
+			"WHERE first = @First AND last = @last",
+			[]interface{}{"John", "Doe"},
+		},
+		{
+			&Person{ID: 7, FirstName: "John", LastName: "Doe"},
+			"WHERE id = @id AND last = @last",
+			[]interface{}{7, "Doe"},
+		},
+	}
+	for _, test := range tests {
+		qc := StmtContext{}
+		if err := WithArgs(test.src)(&qc); err != nil {
+			t.Fatal(err)
+		}
+		_, qargs, _, err := translateQuery(argSyntax{prefix: '@'}, defaultPlaceHolder(), test.query, nil, qc.argSource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(qargs, test.qargs) {
+			t.Errorf("Expected %#v but got %#v", test.qargs, qargs)
+		}
+	}
+	//explicit args win and the first source wins
+	qc := StmtContext{}
+	err := Compose(
+		WithArgs(map[string]interface{}{"last": "Doe", "first": "John"}),
+		WithArgs(&personFilter{LastName: "Moe", Secret: "x"}),
+	)(&qc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, qargs, _, err := translateQuery(
+		argSyntax{prefix: '@'},
+		defaultPlaceHolder(),
+		"@last @first @First @id",
+		[]sql.NamedArg{sql.Named("id", 3)},
+		qc.argSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"Doe", "John", "", 3}; !reflect.DeepEqual(qargs, want) {
+		t.Errorf("Expected %#v but got %#v", want, qargs)
+	}
+	if _, _, _, err := translateQuery(argSyntax{prefix: '@'}, defaultPlaceHolder(), "@Secret", nil, qc.argSource); err == nil {
+		t.Error("expected error for field skipped by dbi tag")
+	}
+	if err := Compose(WithArgs(42))(&qc); err == nil {
+		t.Error("expected error for invalid source of named arguments")
+	}
+}
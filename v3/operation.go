@@ -0,0 +1,75 @@
+package dbi
+
+import (
+	"time"
+)
+
+//opState tracks a single dbi operation for Tracer and Metrics
+type opState struct {
+	trace        TraceInfo
+	start        time.Time
+	rowsScanned  int64
+	rowsAffected int64
+}
+
+//startOp starts tracing and measuring of operation op, model is the model of the operation,
+//dst of Select like operations, prototypes of SelectJoin or nil
+func (db *H) startOp(qc *StmtContext, op string, model interface{}) {
+	qc.operation, qc.target = op, model
+	if db.tracer == nil && db.metrics == nil {
+		return
+	}
+	qc.op = &opState{
+		trace: TraceInfo{Operation: op, Table: traceTable(qc, model), TxID: qc.txID, TxContext: qc.txContext},
+		start: time.Now(),
+	}
+	if db.tracer != nil {
+		if ctx := db.tracer.Start(qc.context, &qc.op.trace); ctx != nil {
+			qc.context = ctx
+		}
+	}
+}
+
+//endOp ends operation started by startOp and returns err wrapped in StmtError
+func (db *H) endOp(qc *StmtContext, err error) error {
+	db.reportSlow(qc)
+	err = db.stmtError(qc, err)
+	if qc.op == nil {
+		return err
+	}
+	if db.metrics != nil {
+		db.metrics.Observe(&Observation{
+			Operation:    qc.op.trace.Operation,
+			Table:        qc.op.trace.Table,
+			Duration:     time.Since(qc.op.start),
+			RowsScanned:  qc.op.rowsScanned,
+			RowsAffected: qc.op.rowsAffected,
+			Err:          err,
+			ErrClass:     errorClass(err),
+		})
+	}
+	if db.tracer != nil {
+		db.tracer.End(qc.context, &qc.op.trace, err)
+	}
+	return err
+}
+
+//traceTable returns table name of the model given to startOp
+func traceTable(qc *StmtContext, model interface{}) string {
+	switch m := model.(type) {
+	case nil:
+		return ""
+	case DBNamer:
+		return m.DBName()
+	case []DBRowUnmarshaler:
+		if len(m) > 0 {
+			return m[0].DBName()
+		}
+		return ""
+	}
+	sd, err := newSliceDst(model, qc)
+	if err != nil {
+		return ""
+	}
+	return sd.source.DBName()
+}
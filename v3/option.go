@@ -2,7 +2,10 @@ package dbi
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"unicode"
 )
 
 //DBOption is configuration option when creating DBI handle
@@ -12,12 +15,17 @@ func (db *H) setLogger(w io.Writer) error {
 	if w == nil {
 		return errors.New("logger writer is nil")
 	}
-	db.lw = w
+	if _, ok := db.logger.(writerLogger); db.logger != nil && !ok {
+		return errLoggerConflict
+	}
+	db.logger = writerLogger{w}
 	return nil
 }
 
 //Logger is an optional configuration option if logging of SQL statements by dbi is desired
 //db, err := New(mySqlConn, Logger(myWriter))
+//Statements are printed before they are executed together with their arguments.
+//Use QueryLog instead for structured events including duration and errors, the two can not be combined.
 func Logger(w io.Writer) DBOption {
 	return func(db *H) error {
 		return db.setLogger(w)
@@ -30,6 +38,8 @@ func Postgres() DBOption {
 	return func(db *H) error {
 		db.placeholder = pgPlaceHolder
 		db.dbType = postgres
+		db.syntax.dollarQuotes = true
+		db.syntax.nestedComments = true
 		return nil
 	}
 }
@@ -40,6 +50,35 @@ func Mysql() DBOption {
 	return func(db *H) error {
 		db.placeholder = defaultPlaceHolder
 		db.dbType = mysql
+		db.syntax.backslashEscapes = true
+		db.syntax.hashComments = true
+		return nil
+	}
+}
+
+//NamedArgPrefix is an optional configuration option to change the prefix of named arguments from the default @
+//e.g. to avoid collisions with SQL Server or MySQL user variables
+//db, err := New(mySqlConn, Mysql(), NamedArgPrefix(':'))
+//A doubled prefix such as :: in casts is always left as it is.
+func NamedArgPrefix(prefix rune) DBOption {
+	return func(db *H) error {
+		if unicode.IsLetter(prefix) || unicode.IsDigit(prefix) || unicode.IsSpace(prefix) ||
+			strings.ContainsRune("_'\"`\\-/*$?;,()", prefix) {
+			return fmt.Errorf("%q can not be used as prefix of named arguments", prefix)
+		}
+		db.syntax.prefix = prefix
+		return nil
+	}
+}
+
+//PositionalArgs is an optional configuration option which allows plain positional arguments
+//to be used instead of named ones, this is useful when migrating existing queries.
+//Positional arguments are created via Positional and the query must use placeholders native to the database
+//db.Select(&persons, nil, "WHERE last = ?", Positional("Moe")...)
+//Named and positional arguments can not be mixed in a single query.
+func PositionalArgs() DBOption {
+	return func(db *H) error {
+		db.syntax.positional = true
 		return nil
 	}
 }
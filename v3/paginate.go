@@ -0,0 +1,432 @@
+package dbi
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ErrInvalidCursor is returned by SelectPage when the cursor can not be decoded
+//or was issued for a different table or sort specification
+var ErrInvalidCursor = errors.New("Invalid pagination cursor")
+
+//ErrInvalidPageSize is returned by SelectPage when the page size is not positive
+var ErrInvalidPageSize = errors.New("SelectPage requires positive page size")
+
+//ErrNoSort is returned by SelectPage when no sort column is given
+var ErrNoSort = errors.New("SelectPage requires at least one sort column")
+
+//ErrUnknownSortColumn is returned by SelectPage when a sort column is not one of DBRow columns
+var ErrUnknownSortColumn = errors.New("Sort column is not one of DBRow columns")
+
+//Sort is a column used to order and paginate results of SelectPage
+type Sort struct {
+	Col  string // column name as returned by DBRow()
+	Desc bool   // descending order
+}
+
+//Asc returns Sort in ascending order by col
+func Asc(col string) Sort {
+	return Sort{Col: col}
+}
+
+//Desc returns Sort in descending order by col
+func Desc(col string) Sort {
+	return Sort{Col: col, Desc: true}
+}
+
+//Page is returned by SelectPage and holds opaque cursors to the neighbouring pages.
+//Cursors are empty when there is no such page.
+type Page struct {
+	Next string
+	Prev string
+}
+
+//SelectPage populates dst with a single page of at most size results using keyset pagination.
+//The results are ordered by sort, the primary key is added as the last sort column if not present
+//so that the order is stable. Sort columns must not contain NULL values.
+//The cursor is either empty for the first page or one of the cursors returned in Page.
+//The filter is an optional condition (without the WHERE keyword) e.g. "last = @last",
+//args are the named arguments of the filter just like in Select.
+//With PositionalArgs option the filter can use positional arguments instead,
+//the cursor values then get placeholders following the ones of the filter.
+func (db *H) SelectPage(
+	dst interface{},
+	optionFunc StmtOption,
+	sort []Sort,
+	size int,
+	cursor string,
+	filter string,
+	args ...sql.NamedArg) (Page, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return Page{}, err
+	}
+	db.startOp(&qc, "SelectPage", dst)
+	page, err := selectPage(db.connection(), db.dbType, db.placeholder, db.syntax, db.queryLogger(), dst, &qc, sort, size, cursor, filter, args...)
+	return page, db.endOp(&qc, err)
+}
+
+//cursorVal is a single typed value stored in a cursor
+type cursorVal struct {
+	T string `json:"t"`
+	V string `json:"v"`
+}
+
+//pageCursor is the decoded form of the opaque cursor
+type pageCursor struct {
+	Back bool        `json:"b,omitempty"` // fetch the page before the values
+	Sort string      `json:"s"`           // table and sort the cursor was issued for
+	Vals []cursorVal `json:"v"`
+}
+
+//sortSignature identifies the table and sort columns a cursor belongs to e.g. person:last,-id
+func sortSignature(table string, sort []Sort) string {
+	var buf bytes.Buffer
+	buf.WriteString(table)
+	buf.WriteString(":")
+	for i, s := range sort {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if s.Desc {
+			buf.WriteString("-")
+		}
+		buf.WriteString(s.Col)
+	}
+	return buf.String()
+}
+
+func encodeCursor(back bool, table string, row []Col, sort []Sort) (string, error) {
+	pc := pageCursor{Back: back, Sort: sortSignature(table, sort)}
+	for _, s := range sort {
+		c := findCol(row, s.Col)
+		cv, err := encodeCursorVal(c.Val)
+		if err != nil {
+			return "", err
+		}
+		pc.Vals = append(pc.Vals, cv)
+	}
+	buf, err := json.Marshal(pc)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func decodeCursor(cursor string, table string, sort []Sort) (pageCursor, []interface{}, error) {
+	var pc pageCursor
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pc, nil, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(buf, &pc); err != nil {
+		return pc, nil, ErrInvalidCursor
+	}
+	if pc.Sort != sortSignature(table, sort) || len(pc.Vals) != len(sort) {
+		return pc, nil, ErrInvalidCursor
+	}
+	vals := make([]interface{}, 0, len(pc.Vals))
+	for _, cv := range pc.Vals {
+		v, err := decodeCursorVal(cv)
+		if err != nil {
+			return pc, nil, ErrInvalidCursor
+		}
+		vals = append(vals, v)
+	}
+	return pc, vals, nil
+}
+
+func encodeCursorVal(v interface{}) (cursorVal, error) {
+	switch v := v.(type) {
+	case int, int64, int32, int16, int8:
+		return cursorVal{"i", fmt.Sprint(v)}, nil
+	case uint, uint64, uint32, uint16, uint8:
+		return cursorVal{"u", fmt.Sprint(v)}, nil
+	case float64:
+		return cursorVal{"f", strconv.FormatFloat(v, 'g', -1, 64)}, nil
+	case float32:
+		return cursorVal{"f", strconv.FormatFloat(float64(v), 'g', -1, 32)}, nil
+	case string:
+		return cursorVal{"s", v}, nil
+	case bool:
+		return cursorVal{"b", strconv.FormatBool(v)}, nil
+	case time.Time:
+		return cursorVal{"t", v.Format(time.RFC3339Nano)}, nil
+	case []byte:
+		return cursorVal{"x", base64.RawURLEncoding.EncodeToString(v)}, nil
+	default:
+		return cursorVal{}, fmt.Errorf("Unsupported type %T of pagination column", v)
+	}
+}
+
+func decodeCursorVal(cv cursorVal) (interface{}, error) {
+	switch cv.T {
+	case "i":
+		return strconv.ParseInt(cv.V, 10, 64)
+	case "u":
+		return strconv.ParseUint(cv.V, 10, 64)
+	case "f":
+		return strconv.ParseFloat(cv.V, 64)
+	case "s":
+		return cv.V, nil
+	case "b":
+		return strconv.ParseBool(cv.V)
+	case "t":
+		return time.Parse(time.RFC3339Nano, cv.V)
+	case "x":
+		return base64.RawURLEncoding.DecodeString(cv.V)
+	default:
+		return nil, ErrInvalidCursor
+	}
+}
+
+func findCol(row []Col, name string) *Col {
+	for i := range row {
+		if row[i].Name == name {
+			return &row[i]
+		}
+	}
+	return nil
+}
+
+//pageSort validates sort against the model columns and adds primary key as tiebreaker
+func pageSort(row []Col, sort []Sort) ([]Sort, error) {
+	if len(sort) == 0 {
+		return nil, ErrNoSort
+	}
+	pk := getPKFromColumns(row)
+	hasPK := false
+	for _, s := range sort {
+		if findCol(row, s.Col) == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSortColumn, s.Col)
+		}
+		if pk != nil && s.Col == pk.Name {
+			hasPK = true
+		}
+	}
+	if pk == nil || hasPK {
+		return sort, nil
+	}
+	full := make([]Sort, len(sort), len(sort)+1)
+	copy(full, sort)
+	return append(full, Sort{Col: pk.Name, Desc: sort[len(sort)-1].Desc}), nil
+}
+
+//keysetPredicate writes condition selecting rows after (or before when back is true) the cursor values
+//the values are referenced as named arguments argName(i)
+func keysetPredicate(buf *bytes.Buffer, dbType dbTyp, sort []Sort, back bool, argName func(int) string) {
+	op := func(s Sort) string {
+		if s.Desc != back {
+			return "<"
+		}
+		return ">"
+	}
+	uniform := true
+	for _, s := range sort {
+		if s.Desc != sort[0].Desc {
+			uniform = false
+		}
+	}
+	//row value comparison is understood by postgres and sqlite
+	//mysql does not use indexes well for it so it gets the expanded form
+	if uniform && len(sort) > 1 && dbType != mysql {
+		buf.WriteString("(")
+		for i, s := range sort {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(s.Col)
+		}
+		buf.WriteString(") ")
+		buf.WriteString(op(sort[0]))
+		buf.WriteString(" (")
+		for i := range sort {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(argName(i))
+		}
+		buf.WriteString(")")
+		return
+	}
+	//(a > x) OR (a = x AND b > y) OR ...
+	buf.WriteString("(")
+	for i, s := range sort {
+		if i > 0 {
+			buf.WriteString(" OR ")
+		}
+		buf.WriteString("(")
+		for j := 0; j < i; j++ {
+			buf.WriteString(sort[j].Col)
+			buf.WriteString(" = ")
+			buf.WriteString(argName(j))
+			buf.WriteString(" AND ")
+		}
+		buf.WriteString(s.Col)
+		buf.WriteString(" ")
+		buf.WriteString(op(s))
+		buf.WriteString(" ")
+		buf.WriteString(argName(i))
+		buf.WriteString(")")
+	}
+	buf.WriteString(")")
+}
+
+func selectPage(
+	conn connection,
+	dbType dbTyp,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	dst interface{},
+	qc *StmtContext,
+	sort []Sort,
+	size int,
+	cursor string,
+	filter string,
+	args ...sql.NamedArg) (Page, error) {
+	var (
+		page Page
+		buf  bytes.Buffer
+		back bool
+	)
+	if size <= 0 {
+		return page, ErrInvalidPageSize
+	}
+	sd, err := newSliceDst(dst, qc)
+	if err != nil {
+		return page, err
+	}
+	sort, err = pageSort(sd.source.DBRow(), sort)
+	if err != nil {
+		return page, err
+	}
+	table := sd.source.DBName()
+	argName := func(i int) string {
+		return fmt.Sprintf("dbi_cursor_%d", i)
+	}
+	var conds []string
+	if strings.TrimSpace(filter) != "" {
+		conds = append(conds, "("+filter+")")
+	}
+	if cursor != "" {
+		pc, vals, err := decodeCursor(cursor, table, sort)
+		if err != nil {
+			return page, err
+		}
+		back = pc.Back
+		argRef := func(i int) string {
+			return string(syntax.prefix) + argName(i)
+		}
+		if len(positionalArgs(args)) > 0 {
+			//named and positional arguments can not be mixed so cursor values become positional too,
+			//they follow the arguments of the filter and are passed once per reference
+			argRef = func(i int) string {
+				args = append(args, sql.NamedArg{Value: vals[i]})
+				if dbType == postgres {
+					return fmt.Sprintf("$%d", len(positionalArgs(args)))
+				}
+				return "?"
+			}
+		} else {
+			for i, v := range vals {
+				args = append(args, sql.Named(argName(i), v))
+			}
+		}
+		var pred bytes.Buffer
+		keysetPredicate(&pred, dbType, sort, back, argRef)
+		conds = append(conds, pred.String())
+	}
+	if len(conds) > 0 {
+		buf.WriteString("WHERE ")
+		buf.WriteString(strings.Join(conds, " AND "))
+	}
+	buf.WriteString(" ORDER BY ")
+	for i, s := range sort {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(s.Col)
+		//going back the order is reversed and results are reversed afterwards
+		if s.Desc != back {
+			buf.WriteString(" DESC")
+		}
+	}
+	//one more row tells whether there are more pages
+	fmt.Fprintf(&buf, " LIMIT %d", size+1)
+
+	query, qargs, names, err := translateQuery(
+		syntax,
+		placeholderMaker(),
+		buildSelect(sd.source, buf.String()),
+		args,
+		qc.argSource)
+	if err != nil {
+		return page, err
+	}
+	ev := newQueryEvent(ql, qc, "SelectPage", sd.source, query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return page, err
+	}
+	defer func() { _ = rows.Close() }()
+	offset := sd.dstv.Len()
+	err = sd.scanRows(rows)
+	ev.RowsScanned = int64(sd.dstv.Len() - offset)
+	err = logQuery(ql, ev, err)
+	if err != nil {
+		return page, err
+	}
+	more := sd.dstv.Len()-offset > size
+	if more {
+		sd.dstv.Set(sd.dstv.Slice(0, offset+size))
+	}
+	//release the connection before running any preload queries
+	if err := rows.Close(); err != nil {
+		return page, err
+	}
+	if err := preload(conn, placeholderMaker, syntax, ql, qc, sd.source, sd.models(offset)); err != nil {
+		return page, err
+	}
+	results := sd.dstv.Slice(offset, sd.dstv.Len())
+	if back {
+		swap := reflect.Swapper(results.Interface())
+		for i, j := 0, results.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+	if results.Len() == 0 {
+		return page, nil
+	}
+	first, last := elemRow(results.Index(0)), elemRow(results.Index(results.Len()-1))
+	//going forward there is a previous page unless this is the first one
+	//going back there is always a next page
+	if (!back && more) || (back && cursor != "") {
+		if page.Next, err = encodeCursor(false, table, last, sort); err != nil {
+			return page, err
+		}
+	}
+	if (back && more) || (!back && cursor != "") {
+		if page.Prev, err = encodeCursor(true, table, first, sort); err != nil {
+			return page, err
+		}
+	}
+	return page, nil
+}
+
+//elemRow returns DBRow() of a slice element which is either a model or pointer to it
+func elemRow(v reflect.Value) []Col {
+	if v.Kind() != reflect.Ptr {
+		v = v.Addr()
+	}
+	return v.Interface().(DBRowMarshaler).DBRow()
+}
@@ -0,0 +1,64 @@
+package dbi
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestKeysetPredicate(t *testing.T) {
+	var tests = []struct {
+		dbType dbTyp
+		sort   []Sort
+		back   bool
+		out    string
+	}{
+		{sqlite, []Sort{Asc("id")}, false, "((id > @k0))"},
+		{sqlite, []Sort{Desc("id")}, true, "((id > @k0))"},
+		{postgres, []Sort{Asc("last"), Asc("id")}, false, "(last,id) > (@k0,@k1)"},
+		{postgres, []Sort{Desc("last"), Desc("id")}, false, "(last,id) < (@k0,@k1)"},
+		{mysql, []Sort{Asc("last"), Asc("id")}, false, "((last > @k0) OR (last = @k0 AND id > @k1))"},
+		{postgres, []Sort{Asc("last"), Desc("id")}, true, "((last < @k0) OR (last = @k0 AND id > @k1))"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		keysetPredicate(&buf, test.dbType, test.sort, test.back, func(i int) string { return fmt.Sprintf("@k%d", i) })
+		if buf.String() != test.out {
+			t.Errorf("Expected\n%s\nbut got\n%s", test.out, buf.String())
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2018, 10, 1, 12, 30, 0, 5, time.UTC)
+	row := []Col{
+		NewCol("id", int64(1<<60), nil),
+		NewCol("name", "Doe", nil),
+		NewCol("at", ts, nil),
+		NewCol("score", 1.5, nil),
+	}
+	sort := []Sort{Asc("name"), Asc("at"), Asc("score"), Asc("id")}
+	cursor, err := encodeCursor(true, "person", row, sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, vals, err := decodeCursor(cursor, "person", sort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pc.Back {
+		t.Error("expected back cursor")
+	}
+	if vals[0] != "Doe" || !vals[1].(time.Time).Equal(ts) || vals[2] != 1.5 || vals[3] != int64(1<<60) {
+		t.Errorf("cursor values do not round-trip %v", vals)
+	}
+	for _, other := range [][]Sort{sort[:2], {Asc("name"), Asc("at"), Asc("score"), Desc("id")}, {Asc("id"), Asc("at"), Asc("score"), Asc("name")}} {
+		if _, _, err := decodeCursor(cursor, "person", other); err != ErrInvalidCursor {
+			t.Errorf("%v: want %v got %v", other, ErrInvalidCursor, err)
+		}
+	}
+	if _, _, err := decodeCursor(cursor, "company", sort); err != ErrInvalidCursor {
+		t.Errorf("want %v got %v", ErrInvalidCursor, err)
+	}
+}
@@ -0,0 +1,223 @@
+package dbi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//preloadBatchSize limits the number of parent keys in a single IN (...) of preload query
+const preloadBatchSize = 500
+
+//ErrPreloadUnsupported is returned when Preload is passed to an operation which does not load models
+//i.e. anything but Select, SelectOne, SelectSQL and SelectPage
+var ErrPreloadUnsupported = errors.New("Preload is only supported by Select, SelectOne, SelectSQL and SelectPage")
+
+//RelationKind tells how models are related
+type RelationKind int
+
+const (
+	//HasManyRelation parent's Local column is referenced by Foreign column of many related models
+	HasManyRelation RelationKind = iota
+	//BelongsToRelation parent's Local column references Foreign column of a single related model
+	BelongsToRelation
+)
+
+//Relation describes a relationship between two models keyed by columns
+type Relation struct {
+	Name    string                  // name used by Preload
+	Kind    RelationKind            // HasManyRelation or BelongsToRelation
+	Local   string                  // column of the parent model as returned by DBRow()
+	Foreign string                  // column of the related model as returned by DBRow()
+	NewFunc func() DBRowUnmarshaler // creates a new related model to scan into
+}
+
+//HasMany returns Relation where parent's local column (usually primary key) is referenced by foreign column of related models
+//e.g. HasMany("reports", "ID", "company_id", func() DBRowUnmarshaler { return &AnnualReport{} })
+func HasMany(name, local, foreign string, newFunc func() DBRowUnmarshaler) Relation {
+	return Relation{Name: name, Kind: HasManyRelation, Local: local, Foreign: foreign, NewFunc: newFunc}
+}
+
+//BelongsTo returns Relation where parent's local column references foreign column (usually primary key) of related model
+//e.g. BelongsTo("company", "company_id", "ID", func() DBRowUnmarshaler { return &Company{} })
+func BelongsTo(name, local, foreign string, newFunc func() DBRowUnmarshaler) Relation {
+	return Relation{Name: name, Kind: BelongsToRelation, Local: local, Foreign: foreign, NewFunc: newFunc}
+}
+
+//DBRelater is implemented by models which declare relations to other models
+type DBRelater interface {
+	DBRelations() []Relation
+}
+
+//DBRelatedSetter is implemented by models which accept related models loaded via Preload.
+//For BelongsToRelation related has at most one element.
+type DBRelatedSetter interface {
+	DBSetRelated(name string, related []DBRowUnmarshaler) error
+}
+
+//Preload returns StmtOption which makes Select, SelectOne, SelectSQL and SelectPage load the named relations
+//of the selected models using one additional query per relation instead of one per model
+//e.g. db.Select(&companies, Preload("reports"), "")
+//Other operations fail with ErrPreloadUnsupported.
+func Preload(names ...string) StmtOption {
+	return func(qc *StmtContext) error {
+		qc.preload = append(qc.preload, names...)
+		return nil
+	}
+}
+
+//noPreload returns ErrPreloadUnsupported when Preload was passed to an operation which does not support it
+func noPreload(qc *StmtContext) error {
+	if len(qc.preload) > 0 {
+		return ErrPreloadUnsupported
+	}
+	return nil
+}
+
+//relationKey turns column value into map key so that e.g. int and int64 of the same value match
+func relationKey(v interface{}) string {
+	return fmt.Sprintf("%v", v)
+}
+
+//preload loads relations named in qc.preload for parents
+func preload(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	qc *StmtContext,
+	source DBRowUnmarshaler,
+	parents []interface{}) error {
+	if len(qc.preload) == 0 || len(parents) == 0 {
+		return nil
+	}
+	relater, ok := source.(DBRelater)
+	if !ok {
+		return fmt.Errorf("%s does not implement DBRelater", source.DBName())
+	}
+	for _, name := range qc.preload {
+		var rel *Relation
+		for _, r := range relater.DBRelations() {
+			if r.Name == name {
+				r := r
+				rel = &r
+				break
+			}
+		}
+		if rel == nil {
+			return fmt.Errorf("%s has no relation named %s", source.DBName(), name)
+		}
+		if err := preloadRelation(conn, placeholderMaker, syntax, ql, qc, rel, parents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func preloadRelation(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	qc *StmtContext,
+	rel *Relation,
+	parents []interface{}) error {
+	//collect distinct parent keys
+	var keys []interface{}
+	seen := make(map[string]bool)
+	for _, p := range parents {
+		c := findCol(p.(DBRowMarshaler).DBRow(), rel.Local)
+		if c == nil {
+			return fmt.Errorf("Relation %s: local column %s is not one of DBRow columns", rel.Name, rel.Local)
+		}
+		k := relationKey(c.Val)
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, c.Val)
+		}
+	}
+	proto := rel.NewFunc()
+	where := fmt.Sprintf("WHERE %s IN (%ckeys)", rel.Foreign, syntax.prefix)
+	related := make(map[string][]DBRowUnmarshaler)
+	for start := 0; start < len(keys); start += preloadBatchSize {
+		end := start + preloadBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		query, qargs, names, err := translateQuery(
+			syntax,
+			placeholderMaker(),
+			buildSelect(proto, where),
+			[]sql.NamedArg{sql.Named("keys", keys[start:end])},
+			nil)
+		if err != nil {
+			return err
+		}
+		ev := newQueryEvent(ql, qc, "Preload", proto, query, qargs, names)
+		ev.RowsScanned, err = scanRelated(conn, qc, rel, query, qargs, related)
+		err = logQuery(ql, ev, err)
+		if err != nil {
+			return err
+		}
+	}
+	//attach to parents
+	for _, p := range parents {
+		setter, ok := p.(DBRelatedSetter)
+		if !ok {
+			return fmt.Errorf("%T does not implement DBRelatedSetter", p)
+		}
+		k := relationKey(findCol(p.(DBRowMarshaler).DBRow(), rel.Local).Val)
+		children := related[k]
+		if rel.Kind == BelongsToRelation && len(children) > 1 {
+			children = children[:1]
+		}
+		if err := setter.DBSetRelated(rel.Name, children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//scanRelated runs the query, groups the related models by their foreign column and returns the number of rows read
+func scanRelated(
+	conn connection,
+	qc *StmtContext,
+	rel *Relation,
+	query string,
+	qargs []interface{},
+	related map[string][]DBRowUnmarshaler) (int64, error) {
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = rows.Close() }()
+	var n int64
+	for rows.Next() {
+		m := rel.NewFunc()
+		if err := m.DBScan(rows); err != nil {
+			return n, err
+		}
+		n++
+		c := findCol(m.DBRow(), rel.Foreign)
+		if c == nil {
+			return n, fmt.Errorf("Relation %s: foreign column %s is not one of DBRow columns", rel.Name, rel.Foreign)
+		}
+		k := relationKey(c.Val)
+		related[k] = append(related[k], m)
+	}
+	return n, rows.Err()
+}
+
+//models returns elements of dst starting at offset as models i.e. pointers
+func (sd *sliceDst) models(offset int) []interface{} {
+	models := make([]interface{}, 0, sd.dstv.Len()-offset)
+	for i := offset; i < sd.dstv.Len(); i++ {
+		v := sd.dstv.Index(i)
+		if v.Kind() != reflect.Ptr {
+			v = v.Addr()
+		}
+		models = append(models, v.Interface())
+	}
+	return models
+}
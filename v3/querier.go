@@ -0,0 +1,37 @@
+package dbi
+
+import "database/sql"
+
+//Querier is the set of operations shared by H and Tx.
+//Code depending on Querier runs both inside and outside of transactions
+//and can be tested without a database e.g. using dbitest.DB.
+type Querier interface {
+	Insert(s DBRowMarshaler, optionFunc StmtOption) (Col, error)
+	Get(s DBRowUnmarshaler, optionFunc StmtOption) error
+	Update(s DBRowUnmarshaler, optionFunc StmtOption) error
+	Delete(s DBRowMarshaler, optionFunc StmtOption) error
+	UpdateWhere(s DBNamer, optionFunc StmtOption, setCols []Col, where string, args ...sql.NamedArg) (int64, error)
+	DeleteWhere(s DBNamer, optionFunc StmtOption, where string, args ...sql.NamedArg) (int64, error)
+	Select(dst interface{}, optionFunc StmtOption, where string, args ...sql.NamedArg) error
+	SelectOne(dst DBRowUnmarshaler, optionFunc StmtOption, where string, args ...sql.NamedArg) error
+	SelectSQL(dst interface{}, optionFunc StmtOption, query string, args ...sql.NamedArg) error
+	SelectValues(dst interface{}, optionFunc StmtOption, query string, args ...sql.NamedArg) error
+	SelectJoin(dst *[]Tuple, optionFunc StmtOption, prototypes []DBRowUnmarshaler, from string, args ...sql.NamedArg) error
+	SelectPage(dst interface{}, optionFunc StmtOption, sort []Sort, size int, cursor string, filter string, args ...sql.NamedArg) (Page, error)
+	Count(s DBNamer, optionFunc StmtOption, where string, args ...sql.NamedArg) (int64, error)
+	Exists(s DBNamer, optionFunc StmtOption, where string, args ...sql.NamedArg) (bool, error)
+	Exec(optionFunc StmtOption, query string, args ...sql.NamedArg) (sql.Result, error)
+	QueryRaw(optionFunc StmtOption, query string, args ...sql.NamedArg) (*sql.Rows, error)
+}
+
+//TxQuerier is Querier of a transaction which can be committed or rolled back, it is satisfied by Tx
+type TxQuerier interface {
+	Querier
+	Commit() error
+	Rollback() error
+}
+
+var (
+	_ Querier   = (*H)(nil)
+	_ TxQuerier = (*Tx)(nil)
+)
@@ -0,0 +1,211 @@
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+//QueryEvent describes a single statement executed by dbi
+type QueryEvent struct {
+	Context      context.Context // context the statement was executed with
+	Operation    string          // dbi operation e.g. Insert, Select, Preload, Begin, Commit
+	Table        string          // table of the model, empty for arbitrary SQL such as Exec
+	SQL          string          // statement as sent to the database
+	Args         []interface{}   // arguments of the statement
+	Start        time.Time       // when the statement was started
+	Duration     time.Duration   // how long it took until the database responded
+	RowsAffected int64           // rows affected by Exec like statements, -1 when not known
+	RowsScanned  int64           // rows read from the result of queries
+	Err          error           // error returned by the database if any
+	TxID         uint64          // id of the transaction the statement ran in, 0 outside of transactions
+
+	model    DBNamer      // model the statement was generated for if any
+	argNames []string     // name of the named argument or column of each of Args, empty when not known
+	op       *opState     // operation the statement is part of if traced or measured
+	qc       *StmtContext // context of the operation, keeps slow statements waiting for EXPLAIN and failures
+}
+
+//QueryLogger receives an event for every statement executed by dbi
+type QueryLogger interface {
+	LogQuery(e *QueryEvent)
+}
+
+//QueryLoggerFunc is an adapter allowing ordinary functions to be used as QueryLogger
+type QueryLoggerFunc func(e *QueryEvent)
+
+//LogQuery calls f(e)
+func (f QueryLoggerFunc) LogQuery(e *QueryEvent) {
+	f(e)
+}
+
+//errLoggerConflict is returned when both Logger and QueryLog options are used
+var errLoggerConflict = errors.New("Logger and QueryLog can not be combined, use only one of them")
+
+//QueryLog is an optional configuration option which reports every statement to l as a structured event
+//db, err := New(mySqlConn, QueryLog(SlogLogger(slog.Default(), slog.LevelDebug)))
+//It can not be combined with Logger.
+func QueryLog(l QueryLogger) DBOption {
+	return func(db *H) error {
+		if l == nil {
+			return errors.New("query logger is nil")
+		}
+		if _, ok := db.logger.(writerLogger); ok {
+			return errLoggerConflict
+		}
+		db.logger = l
+		return nil
+	}
+}
+
+//queryStarter is implemented by loggers which report statements before they are executed
+type queryStarter interface {
+	startQuery(e *QueryEvent)
+}
+
+//writerLogger prints statements and their arguments to w one per line before they are executed as Logger always did
+type writerLogger struct {
+	w io.Writer
+}
+
+func (wl writerLogger) startQuery(e *QueryEvent) {
+	//statements without arguments such as BEGIN or CREATE TABLE were always printed on their own
+	if e.Args == nil {
+		fmt.Fprintln(wl.w, e.SQL)
+		return
+	}
+	//Get and Delete printed their primary key value alone
+	if (e.Operation == "Get" || e.Operation == "Delete") && len(e.Args) == 1 {
+		fmt.Fprintln(wl.w, e.SQL, e.Args[0])
+		return
+	}
+	fmt.Fprintln(wl.w, e.SQL, e.Args)
+}
+
+//LogQuery does nothing since the statement was printed by startQuery
+func (wl writerLogger) LogQuery(e *QueryEvent) {}
+
+//StdLogger returns QueryLogger printing statements via log.Logger together with their duration and error if any
+func StdLogger(l *log.Logger) QueryLogger {
+	return QueryLoggerFunc(func(e *QueryEvent) {
+		switch {
+		case e.Err != nil:
+			l.Printf("%s %s %v %s error: %v", e.Operation, e.SQL, e.Args, e.Duration, e.Err)
+		case e.RowsAffected >= 0:
+			l.Printf("%s %s %v %s rows: %d", e.Operation, e.SQL, e.Args, e.Duration, e.RowsAffected)
+		default:
+			l.Printf("%s %s %v %s", e.Operation, e.SQL, e.Args, e.Duration)
+		}
+	})
+}
+
+//dbLogger reports events of statements executed via db to its logger and slow query callback
+type dbLogger struct {
+	db *H
+}
+
+func (l dbLogger) LogQuery(e *QueryEvent) {
+	db := l.db
+	if db.slow != nil && e.Duration >= db.slow.threshold {
+		db.slow.report(db, e)
+	}
+	if db.logger == nil {
+		return
+	}
+	db.logger.LogQuery(l.redacted(e))
+}
+
+func (l dbLogger) startQuery(e *QueryEvent) {
+	if qs, ok := l.db.logger.(queryStarter); ok {
+		qs.startQuery(l.redacted(e))
+	}
+}
+
+//redacted returns e with sensitive arguments masked
+func (l dbLogger) redacted(e *QueryEvent) *QueryEvent {
+	if args, ok := redactArgs(l.db.redact, e.model, e.argNames, e.Args); ok {
+		masked := *e
+		masked.Args = args
+		return &masked
+	}
+	return e
+}
+
+//queryLogger returns QueryLogger statements of this handle should be reported to, nil when there is none
+func (db *H) queryLogger() QueryLogger {
+	if db.logger == nil && db.slow == nil {
+		return nil
+	}
+	return dbLogger{db}
+}
+
+//txCounter generates ids of transactions
+var txCounter uint64
+
+func nextTxID() uint64 {
+	return atomic.AddUint64(&txCounter, 1)
+}
+
+//newQueryEvent starts event of a statement generated for model (nil for arbitrary SQL) about to be executed,
+//names are the names of args as returned by translateQuery.
+//Loggers reporting statements before they are executed (i.e. Logger) get the event right away.
+func newQueryEvent(ql QueryLogger, qc *StmtContext, op string, model DBNamer, query string, args []interface{}, names []string) QueryEvent {
+	ev := QueryEvent{
+		Context:      qc.context,
+		Operation:    op,
+		SQL:          query,
+		Args:         args,
+		Start:        time.Now(),
+		RowsAffected: -1,
+		TxID:         qc.txID,
+		model:        model,
+		argNames:     names,
+		op:           qc.op,
+		qc:           qc,
+	}
+	if model != nil {
+		ev.Table = model.DBName()
+	}
+	qc.last = stmtInfo{model: model, query: query, args: args, names: names}
+	if qs, ok := ql.(queryStarter); ok {
+		qs.startQuery(&ev)
+	}
+	return ev
+}
+
+//logQuery finishes the event, reports it to ql if any and returns err classified by classifyError
+func logQuery(ql QueryLogger, ev QueryEvent, err error) error {
+	err = classifyError(err, ev.SQL)
+	if err != nil && ev.qc != nil {
+		ev.qc.failed = err
+	}
+	if ev.op != nil {
+		ev.op.rowsScanned += ev.RowsScanned
+		if ev.RowsAffected > 0 {
+			ev.op.rowsAffected += ev.RowsAffected
+		}
+	}
+	if ql == nil {
+		return err
+	}
+	e := ev
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+	ql.LogQuery(&e)
+	return err
+}
+
+//logExec is logQuery for statements returning sql.Result
+func logExec(ql QueryLogger, ev QueryEvent, res sql.Result, err error) error {
+	if err == nil && res != nil {
+		if n, rerr := res.RowsAffected(); rerr == nil {
+			ev.RowsAffected = n
+		}
+	}
+	return logQuery(ql, ev, err)
+}
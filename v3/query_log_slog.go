@@ -0,0 +1,55 @@
+//go:build go1.21
+// +build go1.21
+
+package dbi
+
+import (
+	"context"
+	"log/slog"
+)
+
+//slogLogger writes query events to slog.Logger
+type slogLogger struct {
+	l     *slog.Logger
+	level slog.Level
+}
+
+//SlogLogger returns QueryLogger writing statements to l at level, failed statements are logged at slog.LevelError.
+//The context of the statement is passed on to the handler so that e.g. request ids stored in it can be logged.
+//db, err := New(mySqlConn, QueryLog(SlogLogger(slog.Default(), slog.LevelDebug)))
+func SlogLogger(l *slog.Logger, level slog.Level) QueryLogger {
+	return slogLogger{l: l, level: level}
+}
+
+func (sl slogLogger) LogQuery(e *QueryEvent) {
+	level := sl.level
+	if e.Err != nil {
+		level = slog.LevelError
+	}
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !sl.l.Enabled(ctx, level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 8)
+	attrs = append(attrs, slog.String("op", e.Operation))
+	if e.Table != "" {
+		attrs = append(attrs, slog.String("table", e.Table))
+	}
+	attrs = append(attrs,
+		slog.String("sql", e.SQL),
+		slog.Any("args", e.Args),
+		slog.Duration("duration", e.Duration))
+	if e.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows_affected", e.RowsAffected))
+	}
+	if e.TxID != 0 {
+		attrs = append(attrs, slog.Uint64("tx_id", e.TxID))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, slog.String("error", e.Err.Error()))
+	}
+	sl.l.LogAttrs(ctx, level, "dbi query", attrs...)
+}
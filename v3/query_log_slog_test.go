@@ -0,0 +1,55 @@
+//go:build go1.21
+// +build go1.21
+
+package dbi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := SlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)), slog.LevelDebug)
+	ev := QueryEvent{
+		Context:      context.Background(),
+		Operation:    "Update",
+		Table:        "company",
+		SQL:          "UPDATE company SET Name=? WHERE ID=?",
+		Args:         []interface{}{"IBM", 1},
+		Duration:     time.Millisecond,
+		RowsAffected: 1,
+		TxID:         3,
+	}
+	//debug is below the default info level
+	l.LogQuery(&ev)
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be logged got %s", buf.String())
+	}
+	ev.Err = errors.New("boom")
+	l.LogQuery(&ev)
+	var rec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"level":         "ERROR",
+		"msg":           "dbi query",
+		"op":            "Update",
+		"table":         "company",
+		"sql":           ev.SQL,
+		"rows_affected": float64(1),
+		"tx_id":         float64(3),
+		"error":         "boom",
+	}
+	for k, v := range expected {
+		if rec[k] != v {
+			t.Errorf("expected %s to be %v got %v", k, v, rec[k])
+		}
+	}
+}
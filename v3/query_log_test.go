@@ -0,0 +1,52 @@
+package dbi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWriterLogger(t *testing.T) {
+	var buf bytes.Buffer
+	db, err := New(nil, Logger(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qc := &StmtContext{context: context.Background()}
+	ql := db.queryLogger()
+	ev := newQueryEvent(ql, qc, "Select", nil, "SELECT id FROM person", []interface{}{}, nil)
+	//printed before the statement is executed so that a hanging statement shows up in the log
+	if buf.String() != "SELECT id FROM person []\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+	_ = logQuery(ql, ev, errors.New("failed"))
+	ev = newQueryEvent(ql, qc, "Get", nil, "SELECT id FROM person WHERE id=?", []interface{}{1}, nil)
+	_ = logQuery(ql, ev, nil)
+	ev = newQueryEvent(ql, qc, "Delete", nil, "DELETE FROM person WHERE id=?", []interface{}{2}, nil)
+	_ = logQuery(ql, ev, nil)
+	ev = newQueryEvent(ql, qc, "Update", nil, "UPDATE person SET first=? WHERE id=?", []interface{}{"John", 1}, nil)
+	_ = logQuery(ql, ev, nil)
+	ev = newQueryEvent(ql, qc, "Begin", nil, "BEGIN", nil, nil)
+	_ = logQuery(ql, ev, nil)
+	want := "SELECT id FROM person []\nSELECT id FROM person WHERE id=? 1\nDELETE FROM person WHERE id=? 2\n" +
+		"UPDATE person SET first=? WHERE id=? [John 1]\nBEGIN\n"
+	if buf.String() != want {
+		t.Fatalf("want %q got %q", want, buf.String())
+	}
+}
+
+func TestLoggerConflict(t *testing.T) {
+	var buf bytes.Buffer
+	events := QueryLoggerFunc(func(e *QueryEvent) {})
+	if _, err := New(nil, Logger(&buf), QueryLog(events)); err != errLoggerConflict {
+		t.Errorf("want %v got %v", errLoggerConflict, err)
+	}
+	if _, err := New(nil, QueryLog(events), Logger(&buf)); err != errLoggerConflict {
+		t.Errorf("want %v got %v", errLoggerConflict, err)
+	}
+	//Logger can be replaced by another writer
+	if _, err := New(nil, Logger(&buf), Logger(&buf)); err != nil {
+		t.Error(err)
+	}
+}
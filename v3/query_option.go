@@ -5,8 +5,19 @@ import "context"
 //StmtContext for advanced settings during query execution
 //this will be modified via the StmtOption functions
 type StmtContext struct {
-	newFunc func() DBRowUnmarshaler
-	context context.Context
+	newFunc   func() DBRowUnmarshaler
+	context   context.Context
+	argSource argLookup
+	preload   []string
+	txID      uint64 // set by Tx methods
+	txContext context.Context
+	op        *opState      // set when the operation is traced or measured
+	operation string        // name of the operation set by startOp
+	target    interface{}   // model, dst or prototypes of the operation
+	last      stmtInfo      // last statement executed by the operation
+	txConn    connection    // transaction of Tx operations
+	slow      []pendingSlow // slow statements to be explained and reported by endOp
+	failed    error         // error of the last failed statement, only such errors are wrapped in StmtError
 }
 
 //StmtOption is configuration function to configure QueryContext before executing the query
@@ -31,6 +42,31 @@ func WithContext(ctx context.Context) StmtOption {
 	}
 }
 
+//WithArgs returns a StmtOption that makes the fields of src available as named arguments.
+//src can be a map[string]interface{}, a DBRowMarshaler whose DBRow() column names become arguments
+//or a struct whose field names (or names given in dbi tag e.g. `dbi:"last"`) become arguments.
+//Arguments passed explicitly take precedence and when WithArgs is used several times the first source wins.
+//e.g. db.Select(&persons, WithArgs(filter), "WHERE last = @LastName")
+func WithArgs(src interface{}) StmtOption {
+	return func(qc *StmtContext) error {
+		lookup, err := newArgLookup(src)
+		if err != nil {
+			return err
+		}
+		if prev := qc.argSource; prev != nil {
+			qc.argSource = func(name string) (interface{}, bool) {
+				if v, ok := prev(name); ok {
+					return v, true
+				}
+				return lookup(name)
+			}
+			return nil
+		}
+		qc.argSource = lookup
+		return nil
+	}
+}
+
 //Compose combines several options into one
 //e.g. Compose(WithContext(ctx), WithNewFunc(myInitFunc))
 func Compose(opts ...StmtOption) StmtOption {
@@ -38,7 +74,7 @@ func Compose(opts ...StmtOption) StmtOption {
 		for _, opt := range opts {
 			err := opt(qc)
 			if err != nil {
-				return nil
+				return err
 			}
 		}
 		return nil
@@ -0,0 +1,80 @@
+package dbi
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//RedactedValue replaces values of sensitive columns and arguments in logged statements
+const RedactedValue = "[REDACTED]"
+
+//RedactPattern is an optional configuration option which masks logged values of columns and named arguments
+//whose name matches the regular expression expr (case insensitive) in addition to columns flagged as Sensitive
+//db, err := New(mySqlConn, Logger(myWriter), RedactPattern("passw|token|secret"))
+func RedactPattern(expr string) DBOption {
+	return func(db *H) error {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return fmt.Errorf("invalid redaction pattern: %v", err)
+		}
+		db.redact = append(db.redact, re)
+		return nil
+	}
+}
+
+func (d Col) isSensitive() bool {
+	if d.Opt == nil {
+		return false
+	}
+	return Sensitive == d.Opt.Flags&Sensitive
+}
+
+//redactArgs returns copy of args with values of sensitive columns and arguments masked
+//names are the names of args, model (if any) is checked for columns flagged as Sensitive.
+//The second return value is false when there was nothing to mask and args are returned as they are.
+func redactArgs(patterns []*regexp.Regexp, model DBNamer, names []string, args []interface{}) ([]interface{}, bool) {
+	var (
+		redacted []interface{}
+		row      []Col
+		rowDone  bool
+	)
+	for i, name := range names {
+		if name == "" || i >= len(args) {
+			continue
+		}
+		sensitive := false
+		for _, re := range patterns {
+			if re.MatchString(name) {
+				sensitive = true
+				break
+			}
+		}
+		if !sensitive {
+			if !rowDone {
+				if rm, ok := model.(DBRowMarshaler); ok {
+					row = rm.DBRow()
+				}
+				rowDone = true
+			}
+			for _, c := range row {
+				if c.isSensitive() && strings.EqualFold(c.Name, name) {
+					sensitive = true
+					break
+				}
+			}
+		}
+		if !sensitive {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]interface{}, len(args))
+			copy(redacted, args)
+		}
+		redacted[i] = RedactedValue
+	}
+	if redacted == nil {
+		return args, false
+	}
+	return redacted, true
+}
@@ -0,0 +1,42 @@
+package dbi
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestRedactArgs(t *testing.T) {
+	patterns := []*regexp.Regexp{regexp.MustCompile("(?i)token")}
+	acc := &Account{}
+	tests := []struct {
+		model    DBNamer
+		names    []string
+		args     []interface{}
+		expected []interface{}
+		masked   bool
+	}{
+		{acc, []string{"login", "password", "api_token", "id"}, []interface{}{"moe", "pw", "tk", 1},
+			[]interface{}{"moe", RedactedValue, RedactedValue, 1}, true},
+		//named arguments matching sensitive columns case insensitively
+		{acc, []string{"Password", "Password"}, []interface{}{"a", "b"},
+			[]interface{}{RedactedValue, RedactedValue}, true},
+		//pattern applies without model
+		{nil, []string{"", "refreshToken"}, []interface{}{"x", "y"}, []interface{}{"x", RedactedValue}, true},
+		{&Company{}, []string{"Name"}, []interface{}{"IBM"}, []interface{}{"IBM"}, false},
+		{nil, nil, []interface{}{"pw"}, []interface{}{"pw"}, false},
+	}
+	for _, tc := range tests {
+		orig := append([]interface{}{}, tc.args...)
+		args, masked := redactArgs(patterns, tc.model, tc.names, tc.args)
+		if masked != tc.masked || !reflect.DeepEqual(args, tc.expected) {
+			t.Errorf("expected %v (%v) got %v (%v)", tc.expected, tc.masked, args, masked)
+		}
+		if !reflect.DeepEqual(orig, tc.args) {
+			t.Errorf("args were modified %v", tc.args)
+		}
+	}
+	if err := RedactPattern("(")(&H{}); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
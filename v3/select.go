@@ -3,8 +3,6 @@ package dbi
 import (
 	"bytes"
 	"database/sql"
-	"fmt"
-	"io"
 	"reflect"
 )
 
@@ -24,49 +22,63 @@ func (db *H) Select(
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return selectQuery(db.conn, db.placeholder, db.namedArgPrefix, db.lw, dst, &qc, where, args...)
+	db.startOp(&qc, "Select", dst)
+	err := selectQuery(db.connection(), db.placeholder, db.syntax, db.queryLogger(), dst, &qc, where, args...)
+	return db.endOp(&qc, err)
 }
 
 func selectQuery(
 	conn connection,
 	placeholderMaker func() placeHolderFunc,
-	namedArgPrefix rune,
-	lw io.Writer,
+	syntax argSyntax,
+	ql QueryLogger,
 	dst interface{},
 	qc *StmtContext,
 	where string,
 	args ...sql.NamedArg) error {
-	var (
-		buf          bytes.Buffer
-		btIsPointer  bool
-		baseBaseType reflect.Type
-	)
-	//first reflect base type from dst
-	baseType, err := reflectBaseType(dst)
+	sd, err := newSliceDst(dst, qc)
 	if err != nil {
 		return err
 	}
-	//now figure out if baseType is pointer since we support both
-	if baseType.Kind() == reflect.Ptr {
-		btIsPointer = true
-		baseBaseType = baseType.Elem()
-	} else {
-		baseBaseType = baseType
+	query := buildSelect(sd.source, where)
+	//now translate the query from named format to serial one and populate the args
+	query, qargs, names, err := translateQuery(
+		syntax,
+		placeholderMaker(),
+		query,
+		args,
+		qc.argSource)
+	if err != nil {
+		return err
 	}
-	//crt new pointer to baseBaseType or call newFunc if not nil
-	var newValue reflect.Value
-	if qc.newFunc == nil {
-		newValue = reflect.New(baseBaseType)
-	} else {
-		newValue = reflect.ValueOf(qc.newFunc())
+	ev := newQueryEvent(ql, qc, "Select", sd.source, query, qargs, names)
+	//execute
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return err
 	}
-	source, isUnmarshaler := newValue.Interface().(DBRowUnmarshaler)
-	if !isUnmarshaler {
-		return ErrNoUnmarshaler
+	defer func() { _ = rows.Close() }()
+	offset := sd.dstv.Len()
+	err = sd.scanRows(rows)
+	ev.RowsScanned = int64(sd.dstv.Len() - offset)
+	//log the query to logger
+	err = logQuery(ql, ev, err)
+	if err != nil {
+		return err
+	}
+	//release the connection before running any preload queries
+	if err := rows.Close(); err != nil {
+		return err
 	}
-	row := source.DBRow()
+	return preload(conn, placeholderMaker, syntax, ql, qc, sd.source, sd.models(offset))
+}
+
+//buildSelect returns SELECT col1,col2,... FROM table_name where
+func buildSelect(source DBRowMarshaler, where string) string {
+	var buf bytes.Buffer
 	buf.WriteString("SELECT ")
-	for i, v := range row {
+	for i, v := range source.DBRow() {
 		if i > 0 {
 			buf.WriteString(",")
 		}
@@ -76,64 +88,74 @@ func selectQuery(
 	buf.WriteString(source.DBName())
 	buf.WriteString(" ")
 	buf.WriteString(where)
-	//we are done with assembling the query
-	query := buf.String()
-	//now translate the query from named format to serial one
-	query, keywords, err := produceQuery(
-		namedArgPrefix,
-		placeholderMaker(),
-		query)
-	if err != nil {
-		return err
-	}
+	return buf.String()
+}
+
+//sliceDst wraps the dst argument of Select and friends
+//knowing how to create new elements and append them to dst
+type sliceDst struct {
+	dstv         reflect.Value
+	btIsPointer  bool
+	baseBaseType reflect.Type
+	newFunc      func() DBRowUnmarshaler
+	source       DBRowUnmarshaler //prototype used to call DBName, DBRow
+}
 
-	//populate the args
-	qargs, err := mapNamedArgsToValues(keywords, args)
+func newSliceDst(dst interface{}, qc *StmtContext) (*sliceDst, error) {
+	sd := &sliceDst{newFunc: qc.newFunc}
+	//first reflect base type from dst
+	baseType, err := reflectBaseType(dst)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	//log the query to logger
-	fmt.Fprintln(lw, query, qargs)
-	//execute
-	rows, err := conn.QueryContext(qc.context, query, qargs...)
-	if err != nil {
-		return err
+	//now figure out if baseType is pointer since we support both
+	if baseType.Kind() == reflect.Ptr {
+		sd.btIsPointer = true
+		sd.baseBaseType = baseType.Elem()
+	} else {
+		sd.baseBaseType = baseType
 	}
-	defer func() { _ = rows.Close() }()
-	dstv := reflect.ValueOf(dst).Elem()
-	for rows.Next() {
-		var rowScn DBScanner
-		if qc.newFunc == nil {
-			rowScn = reflect.New(baseBaseType).Interface().(DBScanner)
-		} else {
-			rowScn = qc.newFunc()
-		}
-		err = rowScn.DBScan(rows)
-		if err != nil {
-			return err
-		}
-		vToAppend := reflect.ValueOf(rowScn)
-		if !btIsPointer {
-			vToAppend = vToAppend.Elem()
-		}
-		dstv.Set(reflect.Append(dstv, vToAppend))
+	//crt new pointer to baseBaseType or call newFunc if not nil
+	var newValue reflect.Value
+	if qc.newFunc == nil {
+		newValue = reflect.New(sd.baseBaseType)
+	} else {
+		newValue = reflect.ValueOf(qc.newFunc())
+	}
+	source, isUnmarshaler := newValue.Interface().(DBRowUnmarshaler)
+	if !isUnmarshaler {
+		return nil, ErrNoUnmarshaler
+	}
+	sd.source = source
+	sd.dstv = reflect.ValueOf(dst).Elem()
+	return sd, nil
+}
+
+//newElem returns a new element ready to be scanned into
+func (sd *sliceDst) newElem() DBScanner {
+	if sd.newFunc == nil {
+		return reflect.New(sd.baseBaseType).Interface().(DBScanner)
 	}
-	return nil
+	return sd.newFunc()
 }
 
-func mapNamedArgsToValues(keywords []string, args []sql.NamedArg) ([]interface{}, error) {
-	kmap := make(map[string]interface{})
-	for _, v := range args {
-		kmap[v.Name] = v.Value
+//append adds scanned element to dst
+func (sd *sliceDst) append(rowScn DBScanner) {
+	vToAppend := reflect.ValueOf(rowScn)
+	if !sd.btIsPointer {
+		vToAppend = vToAppend.Elem()
 	}
-	qargs := make([]interface{}, 0, len(keywords))
-	for _, keyword := range keywords {
-		v, ok := kmap[keyword]
-		if !ok {
-			return qargs, fmt.Errorf(
-				"No such named keyword argument: %s", keyword)
+	sd.dstv.Set(reflect.Append(sd.dstv, vToAppend))
+}
+
+//scanRows scans all rows into new elements of dst
+func (sd *sliceDst) scanRows(rows *sql.Rows) error {
+	for rows.Next() {
+		rowScn := sd.newElem()
+		if err := rowScn.DBScan(rows); err != nil {
+			return err
 		}
-		qargs = append(qargs, v)
+		sd.append(rowScn)
 	}
-	return qargs, nil
+	return rows.Err()
 }
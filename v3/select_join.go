@@ -0,0 +1,180 @@
+package dbi
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//ErrNoPrototypes is returned by SelectJoin when no model prototypes are given
+var ErrNoPrototypes = errors.New("SelectJoin requires at least one model prototype")
+
+//Tuple is a single row of SelectJoin results holding one model per prototype
+type Tuple []DBRowUnmarshaler
+
+//aliasedModel overrides DBName of a prototype with table alias used in the query
+type aliasedModel struct {
+	DBRowUnmarshaler
+	alias string
+}
+
+func (a aliasedModel) DBName() string {
+	return a.alias
+}
+
+//JoinAs returns prototype for SelectJoin whose columns are prefixed with alias instead of DBName()
+//e.g. to join the same table twice
+func JoinAs(model DBRowUnmarshaler, alias string) DBRowUnmarshaler {
+	return aliasedModel{model, alias}
+}
+
+//SelectJoin runs a query joining tables of several models and populates dst with one Tuple per row.
+//The column list is generated from DBRow() of each prototype with columns prefixed by DBName() (or alias given via JoinAs)
+//followed by from e.g. "FROM company JOIN annual_report ON annual_report.company_id = company.ID WHERE year = @year".
+//Each tuple holds new models of the same types as the prototypes, each scanned using its own DBScan
+//and only the span of columns belonging to it. Prototypes must be pointers.
+func (db *H) SelectJoin(
+	dst *[]Tuple,
+	optionFunc StmtOption,
+	prototypes []DBRowUnmarshaler,
+	from string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	db.startOp(&qc, "SelectJoin", prototypes)
+	err := selectJoin(db.connection(), db.placeholder, db.syntax, db.queryLogger(), dst, &qc, prototypes, from, args...)
+	return db.endOp(&qc, err)
+}
+
+//joinPart knows how to create new models of a prototype
+type joinPart struct {
+	typ  reflect.Type // type the prototype points to
+	span int          // number of columns selected for the prototype i.e. length of its DBRow()
+}
+
+func (jp *joinPart) newModel() DBRowUnmarshaler {
+	return reflect.New(jp.typ).Interface().(DBRowUnmarshaler)
+}
+
+//replayScanner hands values of already scanned columns to DBScan
+type replayScanner struct {
+	values  []interface{}
+	scanned int // number of columns handed out so far
+}
+
+func (rs *replayScanner) Scan(dest ...interface{}) error {
+	if len(dest) > len(rs.values) {
+		return fmt.Errorf("DBScan scans %d more columns than DBRow has", len(dest)-len(rs.values))
+	}
+	for i, d := range dest {
+		if err := convertAssign(d, rs.values[i]); err != nil {
+			return fmt.Errorf("Scan error on column %d: %v", rs.scanned+i, err)
+		}
+	}
+	rs.values = rs.values[len(dest):]
+	rs.scanned += len(dest)
+	return nil
+}
+
+func newJoinPart(proto DBRowUnmarshaler) (*joinPart, error) {
+	if a, ok := proto.(aliasedModel); ok {
+		proto = a.DBRowUnmarshaler
+	}
+	typ := reflect.TypeOf(proto)
+	if typ.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("Expected pointer as prototype but got %s", typ)
+	}
+	return &joinPart{typ: typ.Elem(), span: len(proto.DBRow())}, nil
+}
+
+func selectJoin(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	dst *[]Tuple,
+	qc *StmtContext,
+	prototypes []DBRowUnmarshaler,
+	from string,
+	args ...sql.NamedArg) error {
+	if err := noPreload(qc); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if len(prototypes) == 0 {
+		return ErrNoPrototypes
+	}
+	parts := make([]*joinPart, 0, len(prototypes))
+	buf.WriteString("SELECT ")
+	for i, proto := range prototypes {
+		jp, err := newJoinPart(proto)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, jp)
+		for j, c := range proto.DBRow() {
+			if i > 0 || j > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(proto.DBName())
+			buf.WriteString(".")
+			buf.WriteString(c.Name)
+		}
+	}
+	buf.WriteString(" ")
+	buf.WriteString(from)
+	query, qargs, names, err := translateQuery(syntax, placeholderMaker(), buf.String(), args, qc.argSource)
+	if err != nil {
+		return err
+	}
+	ev := newQueryEvent(ql, qc, "SelectJoin", prototypes[0], query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	offset := len(*dst)
+	err = scanTuples(rows, parts, dst)
+	ev.RowsScanned = int64(len(*dst) - offset)
+	err = logQuery(ql, ev, err)
+	return err
+}
+
+//scanTuples scans rows into tuples of models created by parts,
+//each row is scanned as a whole and then every model gets the span of its columns via DBScan
+func scanTuples(rows *sql.Rows, parts []*joinPart, dst *[]Tuple) error {
+	var width int
+	for _, jp := range parts {
+		width += jp.span
+	}
+	for rows.Next() {
+		values := make([]interface{}, width)
+		dests := make([]interface{}, width)
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err := rows.Scan(dests...); err != nil {
+			return err
+		}
+		tuple := make(Tuple, 0, len(parts))
+		for _, jp := range parts {
+			rs := &replayScanner{values: values[:jp.span]}
+			values = values[jp.span:]
+			m := jp.newModel()
+			if err := m.DBScan(rs); err != nil {
+				return err
+			}
+			if len(rs.values) > 0 {
+				return fmt.Errorf("%s DBScan left %d of %d columns of DBRow unscanned", m.DBName(), len(rs.values), jp.span)
+			}
+			tuple = append(tuple, m)
+		}
+		*dst = append(*dst, tuple)
+	}
+	return rows.Err()
+}
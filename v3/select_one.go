@@ -0,0 +1,180 @@
+package dbi
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+)
+
+//ErrMultipleRows is returned by SelectOne when the query matches more than one row
+var ErrMultipleRows = errors.New("Query returned more than one row")
+
+//SelectOne runs an SQL query like Select but scans the single resulting row into dst.
+//It returns ErrNotFound when no row matches and ErrMultipleRows when more than one does.
+func (db *H) SelectOne(
+	dst DBRowUnmarshaler,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	db.startOp(&qc, "SelectOne", dst)
+	err := selectOne(db.connection(), db.placeholder, db.syntax, db.queryLogger(), dst, &qc, where, args...)
+	return db.endOp(&qc, err)
+}
+
+//Count returns the number of rows of the model's table matching where
+func (db *H) Count(
+	s DBNamer,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	db.startOp(&qc, "Count", s)
+	n, err := count(db.connection(), db.placeholder, db.syntax, db.queryLogger(), s, &qc, where, args...)
+	return n, db.endOp(&qc, err)
+}
+
+//Exists reports whether any row of the model's table matches where
+func (db *H) Exists(
+	s DBNamer,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) (bool, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return false, err
+	}
+	db.startOp(&qc, "Exists", s)
+	ok, err := exists(db.connection(), db.placeholder, db.syntax, db.queryLogger(), s, &qc, where, args...)
+	return ok, db.endOp(&qc, err)
+}
+
+func selectOne(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	dst DBRowUnmarshaler,
+	qc *StmtContext,
+	where string,
+	args ...sql.NamedArg) error {
+	query, qargs, names, err := translateQuery(
+		syntax,
+		placeholderMaker(),
+		buildSelect(dst, where),
+		args,
+		qc.argSource)
+	if err != nil {
+		return err
+	}
+	ev := newQueryEvent(ql, qc, "SelectOne", dst, query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	err = scanOne(rows, dst)
+	if err == nil {
+		ev.RowsScanned = 1
+	}
+	err = logQuery(ql, ev, err)
+	if err != nil {
+		return err
+	}
+	//release the connection before running any preload queries
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return preload(conn, placeholderMaker, syntax, ql, qc, dst, []interface{}{dst})
+}
+
+//scanOne scans the only row of rows into dst
+func scanOne(rows *sql.Rows, dst DBScanner) error {
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrNotFound
+	}
+	if err := dst.DBScan(rows); err != nil {
+		return err
+	}
+	if rows.Next() {
+		return ErrMultipleRows
+	}
+	return rows.Err()
+}
+
+func count(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	s DBNamer,
+	qc *StmtContext,
+	where string,
+	args ...sql.NamedArg) (int64, error) {
+	if err := noPreload(qc); err != nil {
+		return 0, err
+	}
+	var (
+		buf bytes.Buffer
+		n   int64
+	)
+	buf.WriteString("SELECT COUNT(*) FROM ")
+	buf.WriteString(s.DBName())
+	buf.WriteString(" ")
+	buf.WriteString(where)
+	query, qargs, names, err := translateQuery(syntax, placeholderMaker(), buf.String(), args, qc.argSource)
+	if err != nil {
+		return 0, err
+	}
+	ev := newQueryEvent(ql, qc, "Count", s, query, qargs, names)
+	err = conn.QueryRowContext(qc.context, query, qargs...).Scan(&n)
+	if err == nil {
+		ev.RowsScanned = 1
+	}
+	err = logQuery(ql, ev, err)
+	return n, err
+}
+
+func exists(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	s DBNamer,
+	qc *StmtContext,
+	where string,
+	args ...sql.NamedArg) (bool, error) {
+	if err := noPreload(qc); err != nil {
+		return false, err
+	}
+	var (
+		buf bytes.Buffer
+		ok  bool
+	)
+	buf.WriteString("SELECT EXISTS (SELECT 1 FROM ")
+	buf.WriteString(s.DBName())
+	buf.WriteString(" ")
+	buf.WriteString(where)
+	buf.WriteString(")")
+	query, qargs, names, err := translateQuery(syntax, placeholderMaker(), buf.String(), args, qc.argSource)
+	if err != nil {
+		return false, err
+	}
+	ev := newQueryEvent(ql, qc, "Exists", s, query, qargs, names)
+	err = conn.QueryRowContext(qc.context, query, qargs...).Scan(&ok)
+	if err == nil {
+		ev.RowsScanned = 1
+	}
+	err = logQuery(ql, ev, err)
+	return ok, err
+}
@@ -0,0 +1,104 @@
+package dbi
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+//SelectSQL runs an arbitrary SQL query (joins, CTEs, subqueries...) and populates dst.
+//Unlike Select the query is used as is, only the named arguments are translated.
+//Each row is scanned using DBScan of the dst element type therefore the query
+//must return the same number of columns as DBRow() of that type, otherwise an error listing the mismatch is returned.
+func (db *H) SelectSQL(
+	dst interface{},
+	optionFunc StmtOption,
+	query string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	db.startOp(&qc, "SelectSQL", dst)
+	err := selectSQL(db.connection(), db.placeholder, db.syntax, db.queryLogger(), dst, &qc, query, args...)
+	return db.endOp(&qc, err)
+}
+
+func selectSQL(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	dst interface{},
+	qc *StmtContext,
+	query string,
+	args ...sql.NamedArg) error {
+	sd, err := newSliceDst(dst, qc)
+	if err != nil {
+		return err
+	}
+	query, qargs, names, err := translateQuery(syntax, placeholderMaker(), query, args, qc.argSource)
+	if err != nil {
+		return err
+	}
+	ev := newQueryEvent(ql, qc, "SelectSQL", sd.source, query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err == nil {
+		err = checkColumns(sd.source, columns)
+	}
+	offset := sd.dstv.Len()
+	if err == nil {
+		err = sd.scanRows(rows)
+		ev.RowsScanned = int64(sd.dstv.Len() - offset)
+	}
+	err = logQuery(ql, ev, err)
+	if err != nil {
+		return err
+	}
+	//release the connection before running any preload queries
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return preload(conn, placeholderMaker, syntax, ql, qc, sd.source, sd.models(offset))
+}
+
+//checkColumns verifies the query returns as many columns as DBRow() of source has
+func checkColumns(source DBRowMarshaler, columns []string) error {
+	row := source.DBRow()
+	if len(row) == len(columns) {
+		return nil
+	}
+	names := make([]string, 0, len(row))
+	for _, c := range row {
+		names = append(names, c.Name)
+	}
+	var missing, extra []string
+	for _, n := range names {
+		if !containsFold(columns, n) {
+			missing = append(missing, n)
+		}
+	}
+	for _, n := range columns {
+		if !containsFold(names, n) {
+			extra = append(extra, n)
+		}
+	}
+	return fmt.Errorf(
+		"Query returns %d columns %v but %s DBRow has %d columns %v (missing from query: %v, not in DBRow: %v)",
+		len(columns), columns, source.DBName(), len(names), names, missing, extra)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
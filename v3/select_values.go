@@ -0,0 +1,187 @@
+package dbi
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//ErrUnsupportedDst is returned by SelectValues when dst is not a pointer to a slice of maps, primitive values or structs
+var ErrUnsupportedDst = errors.New("Expected dst to be a pointer to a slice of map[string]interface{}, primitive values or structs")
+
+//SelectValues runs an arbitrary SQL query e.g. "SELECT year, SUM(sales) AS sales FROM annual_report GROUP BY year"
+//and populates dst with plain Go values. dst must be a pointer to one of
+// - []map[string]interface{} holding the values returned by the driver keyed by column name
+// - slice of primitive values (or types implementing sql.Scanner) when the query returns a single column
+// - slice of structs (or pointers to structs) whose fields are matched to columns by dbi tag e.g. `dbi:"year"`
+//   or by case insensitive name ignoring underscores in column names, all columns must be matched.
+//Named arguments are used just like in Select.
+func (db *H) SelectValues(
+	dst interface{},
+	optionFunc StmtOption,
+	query string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	db.startOp(&qc, "SelectValues", dst)
+	err := selectValues(db.connection(), db.placeholder, db.syntax, db.queryLogger(), dst, &qc, query, args...)
+	return db.endOp(&qc, err)
+}
+
+var (
+	mapType     = reflect.TypeOf(map[string]interface{}{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+//isPrimitive reports whether values of typ are scanned directly rather than field by field
+func isPrimitive(typ reflect.Type) bool {
+	if typ == timeType || reflect.PtrTo(typ).Implements(scannerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Func, reflect.Chan, reflect.Interface, reflect.Array:
+		return false
+	case reflect.Slice:
+		return typ.Elem().Kind() == reflect.Uint8
+	case reflect.Ptr:
+		return isPrimitive(typ.Elem())
+	default:
+		return true
+	}
+}
+
+//fieldForColumn returns index of struct field matching column
+func fieldForColumn(typ reflect.Type, column string) ([]int, bool) {
+	plain := strings.Replace(column, "_", "", -1)
+	var byName []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		switch tag := f.Tag.Get("dbi"); {
+		case tag == "-":
+			continue
+		case tag == column:
+			return f.Index, true
+		case tag == "" && byName == nil && (strings.EqualFold(f.Name, column) || strings.EqualFold(f.Name, plain)):
+			byName = f.Index
+		}
+	}
+	return byName, byName != nil
+}
+
+func selectValues(
+	conn connection,
+	placeholderMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	dst interface{},
+	qc *StmtContext,
+	query string,
+	args ...sql.NamedArg) error {
+	if err := noPreload(qc); err != nil {
+		return err
+	}
+	elemType, err := reflectBaseType(dst)
+	if err != nil {
+		return err
+	}
+	isPtr := elemType.Kind() == reflect.Ptr && !isPrimitive(elemType)
+	baseType := elemType
+	if isPtr {
+		baseType = elemType.Elem()
+	}
+	if baseType.Kind() == reflect.Map && (baseType != mapType || isPtr) {
+		return ErrUnsupportedDst
+	}
+	if !isPrimitive(baseType) && baseType.Kind() != reflect.Struct && baseType != mapType {
+		return ErrUnsupportedDst
+	}
+	query, qargs, names, err := translateQuery(syntax, placeholderMaker(), query, args, qc.argSource)
+	if err != nil {
+		return err
+	}
+	ev := newQueryEvent(ql, qc, "SelectValues", nil, query, qargs, names)
+	rows, err := conn.QueryContext(qc.context, query, qargs...)
+	if err != nil {
+		err = logQuery(ql, ev, err)
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	dstv := reflect.ValueOf(dst).Elem()
+	offset := dstv.Len()
+	err = scanValues(rows, dst, baseType, isPtr)
+	ev.RowsScanned = int64(dstv.Len() - offset)
+	err = logQuery(ql, ev, err)
+	return err
+}
+
+//scanValues scans rows into dst whose elements are of baseType or pointers to it when isPtr
+func scanValues(rows *sql.Rows, dst interface{}, baseType reflect.Type, isPtr bool) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var fields [][]int
+	switch {
+	case baseType == mapType:
+	case isPrimitive(baseType):
+		if len(columns) != 1 {
+			return fmt.Errorf("Expected query to return a single column for %s but got %d columns %v", baseType, len(columns), columns)
+		}
+	default:
+		for _, col := range columns {
+			idx, ok := fieldForColumn(baseType, col)
+			if !ok {
+				return fmt.Errorf("Column %s has no matching field in %s", col, baseType)
+			}
+			fields = append(fields, idx)
+		}
+	}
+	dstv := reflect.ValueOf(dst).Elem()
+	for rows.Next() {
+		var elem reflect.Value
+		switch {
+		case baseType == mapType:
+			values := make([]interface{}, len(columns))
+			dests := make([]interface{}, len(columns))
+			for i := range values {
+				dests[i] = &values[i]
+			}
+			if err := rows.Scan(dests...); err != nil {
+				return err
+			}
+			m := make(map[string]interface{}, len(columns))
+			for i, col := range columns {
+				m[col] = values[i]
+			}
+			elem = reflect.ValueOf(m)
+		case isPrimitive(baseType):
+			elem = reflect.New(baseType)
+			if err := rows.Scan(elem.Interface()); err != nil {
+				return err
+			}
+		default:
+			elem = reflect.New(baseType)
+			dests := make([]interface{}, 0, len(fields))
+			for _, idx := range fields {
+				dests = append(dests, elem.Elem().FieldByIndex(idx).Addr().Interface())
+			}
+			if err := rows.Scan(dests...); err != nil {
+				return err
+			}
+		}
+		if elem.Kind() == reflect.Ptr && !isPtr {
+			elem = elem.Elem()
+		}
+		dstv.Set(reflect.Append(dstv, elem))
+	}
+	return rows.Err()
+}
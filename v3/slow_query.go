@@ -0,0 +1,193 @@
+package dbi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+//SlowQuery describes a statement which took longer than the threshold set via SlowQueries
+type SlowQuery struct {
+	Context   context.Context // context the statement was executed with
+	Operation string          // dbi operation e.g. Select
+	Table     string          // table of the model, empty for arbitrary SQL
+	SQL       string          // statement as sent to the database
+	Args      []interface{}   // arguments of the statement with sensitive values masked
+	Elapsed   time.Duration   // how long the statement took
+	Caller    string          // file:line of the code which called dbi
+	TxID      uint64          // id of the transaction the statement ran in, 0 outside of transactions
+	Plan      string          // output of EXPLAIN when enabled via ExplainSlowQueries, one row per line
+	PlanErr   error           // error running EXPLAIN if any
+}
+
+//slowQueries is the configuration of slow query reporting
+type slowQueries struct {
+	threshold time.Duration
+	callback  func(q *SlowQuery)
+	explain   bool
+}
+
+//SlowQueries is an optional configuration option which calls report for every statement taking threshold or longer
+//db, err := New(mySqlConn, SlowQueries(time.Second, func(q *SlowQuery) { log.Println(q.Caller, q.Elapsed, q.SQL) }))
+func SlowQueries(threshold time.Duration, report func(q *SlowQuery)) DBOption {
+	return func(db *H) error {
+		if report == nil {
+			return errors.New("slow query report function is nil")
+		}
+		explain := db.slow != nil && db.slow.explain
+		db.slow = &slowQueries{threshold: threshold, callback: report, explain: explain}
+		return nil
+	}
+}
+
+//ExplainSlowQueries is an optional configuration option which runs EXPLAIN (EXPLAIN QUERY PLAN on SQLite)
+//for statements reported via SlowQueries and passes the plan along in SlowQuery.Plan.
+//EXPLAIN runs only for SELECT, INSERT, UPDATE, DELETE and WITH statements once the operation is done
+//and its rows are closed, so slow statements are reported when the operation returns.
+//Statements of a transaction are explained in that transaction unless they failed,
+//other statements on any connection of the pool (on SQLite :memory: use a single connection).
+//Statements of QueryRaw are not explained as their rows are still in use by the caller.
+//db, err := New(mySqlConn, SlowQueries(time.Second, reportFn), ExplainSlowQueries())
+func ExplainSlowQueries() DBOption {
+	return func(db *H) error {
+		if db.slow == nil {
+			db.slow = &slowQueries{}
+		}
+		db.slow.explain = true
+		return nil
+	}
+}
+
+//explainTimeout bounds EXPLAIN of a slow statement
+const explainTimeout = 5 * time.Second
+
+//pendingSlow is a slow statement waiting for EXPLAIN until its operation is done
+type pendingSlow struct {
+	query   *SlowQuery
+	args    []interface{} // arguments EXPLAIN runs with, not masked
+	explain bool
+}
+
+func (sq *slowQueries) report(db *H, e *QueryEvent) {
+	if sq.callback == nil {
+		return
+	}
+	args, _ := redactArgs(db.redact, e.model, e.argNames, e.Args)
+	q := &SlowQuery{
+		Context:   e.Context,
+		Operation: e.Operation,
+		Table:     e.Table,
+		SQL:       e.SQL,
+		Args:      args,
+		Elapsed:   e.Duration,
+		Caller:    callSite(),
+		TxID:      e.TxID,
+	}
+	if !sq.explain || e.qc == nil {
+		sq.callback(q)
+		return
+	}
+	//EXPLAIN waits for endOp as the statement may still hold its connection
+	e.qc.slow = append(e.qc.slow, pendingSlow{
+		query:   q,
+		args:    e.Args,
+		explain: explainable(e.SQL) && e.Operation != "QueryRaw" && (e.TxID == 0 || e.Err == nil),
+	})
+}
+
+//reportSlow explains and reports slow statements of the operation of qc
+func (db *H) reportSlow(qc *StmtContext) {
+	pending := qc.slow
+	qc.slow = nil
+	if len(pending) == 0 || db.slow == nil {
+		return
+	}
+	var conn connection = db.conn
+	if qc.txConn != nil {
+		conn = qc.txConn
+	}
+	for _, p := range pending {
+		if p.explain {
+			ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+			p.query.Plan, p.query.PlanErr = explain(ctx, conn, db.dbType, p.query.SQL, p.args)
+			cancel()
+		}
+		db.slow.callback(p.query)
+	}
+}
+
+//explainable reports whether EXPLAIN can be run for query
+func explainable(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH":
+		return true
+	}
+	return false
+}
+
+//explain returns the plan of query run on conn formatted one row per line
+func explain(ctx context.Context, conn connection, typ dbTyp, query string, args []interface{}) (string, error) {
+	prefix := "EXPLAIN "
+	if typ == sqlite {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	rows, err := conn.QueryContext(ctx, prefix+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dests := make([]interface{}, len(columns))
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err := rows.Scan(dests...); err != nil {
+			return "", err
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			cells[i] = fmt.Sprint(v)
+		}
+		lines = append(lines, strings.Join(cells, " "))
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
+
+//pkgDir is the directory of dbi sources used to skip dbi frames when looking for the caller
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+//callSite returns file:line of the first caller outside of dbi
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if f.File != "" && (filepath.Dir(f.File) != pkgDir || strings.HasSuffix(f.File, "_test.go")) {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
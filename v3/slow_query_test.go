@@ -0,0 +1,67 @@
+package dbi
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainSingleConnection(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	//EXPLAIN must neither wait for a second connection nor run on another in-memory database
+	conn.SetMaxOpenConns(1)
+	var slow []*SlowQuery
+	db, err := New(conn, ExplainSlowQueries(), SlowQueries(0, func(q *SlowQuery) {
+		slow = append(slow, q)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tx, err := db.Begin()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer tx.Rollback()
+		//the table only exists in the transaction
+		if _, err := tx.Exec(nil, "CREATE TABLE company (ID integer PRIMARY KEY, Name varchar(255), Ticker varchar(255))"); err != nil {
+			t.Error(err)
+			return
+		}
+		var companies []Company
+		if err := tx.Select(&companies, nil, ""); err != nil {
+			t.Error(err)
+			return
+		}
+		rows, err := tx.QueryRaw(nil, "SELECT 1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = rows.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("EXPLAIN of slow statement deadlocked")
+	}
+	if len(slow) != 5 {
+		t.Fatalf("expected 5 slow queries got %d", len(slow))
+	}
+	sel, raw := slow[2], slow[3]
+	if sel.Operation != "Select" || sel.TxID == 0 || sel.PlanErr != nil || !strings.Contains(strings.ToLower(sel.Plan), "company") {
+		t.Fatalf("unexpected slow query %+v", sel)
+	}
+	//rows of QueryRaw are still in use when the operation returns
+	if raw.Operation != "QueryRaw" || raw.Plan != "" || raw.PlanErr != nil {
+		t.Fatalf("unexpected slow query %+v", raw)
+	}
+}
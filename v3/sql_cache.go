@@ -0,0 +1,191 @@
+package dbi
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+)
+
+//sqlOp is the kind of statement generated from a model
+type sqlOp int
+
+const (
+	getOp sqlOp = iota
+	insertOp
+	updateOp
+	deleteOp
+)
+
+//sqlKey identifies generated statement text
+//the model type is part of the key so that different models sharing a table do not evict each other
+type sqlKey struct {
+	dbType dbTyp
+	op     sqlOp
+	typ    reflect.Type
+	table  string
+}
+
+//colSig is the part of a column which affects generated SQL
+type colSig struct {
+	name  string
+	flags ColOptFlag
+}
+
+//sqlTemplate is statement text generated once per dialect, table and column set,
+//only the argument values are extracted from DBRow() on every call
+type sqlTemplate struct {
+	query     string
+	returning string   // postgres insert returning the primary key
+	cols      []colSig // columns the query was generated from
+	args      []int    // indexes of DBRow() columns used as arguments in order
+	names     []string // names of the columns used as arguments
+	pk        int      // index of primary key column or -1
+}
+
+var sqlTemplates = struct {
+	sync.RWMutex
+	m map[sqlKey]*sqlTemplate
+}{m: make(map[sqlKey]*sqlTemplate)}
+
+func colFlags(c Col) ColOptFlag {
+	if c.Opt == nil {
+		return 0
+	}
+	return c.Opt.Flags
+}
+
+//matches reports whether row has the same columns the template was generated from
+func (t *sqlTemplate) matches(row []Col) bool {
+	if len(row) != len(t.cols) {
+		return false
+	}
+	for i, c := range row {
+		if c.Name != t.cols[i].name || colFlags(c) != t.cols[i].flags {
+			return false
+		}
+	}
+	return true
+}
+
+//values returns the arguments of the statement taken from row
+func (t *sqlTemplate) values(row []Col) []interface{} {
+	args := make([]interface{}, len(t.args))
+	for i, idx := range t.args {
+		args[i] = row[idx].Val
+	}
+	return args
+}
+
+//sqlFor returns statement of kind op for model s with columns row, generating it on first use.
+//Models whose DBRow() changes shape are supported, the statement is regenerated when the columns differ.
+func sqlFor(op sqlOp, dbType dbTyp, phMaker func() placeHolderFunc, s DBNamer, row []Col) (*sqlTemplate, error) {
+	key := sqlKey{dbType: dbType, op: op, typ: reflect.TypeOf(s), table: s.DBName()}
+	sqlTemplates.RLock()
+	t, ok := sqlTemplates.m[key]
+	sqlTemplates.RUnlock()
+	if ok && t.matches(row) {
+		return t, nil
+	}
+	t, err := buildSQL(op, dbType, phMaker, key.table, row)
+	if err != nil {
+		return nil, err
+	}
+	sqlTemplates.Lock()
+	sqlTemplates.m[key] = t
+	sqlTemplates.Unlock()
+	return t, nil
+}
+
+//buildSQL generates statement of kind op for table with columns row
+func buildSQL(op sqlOp, dbType dbTyp, phMaker func() placeHolderFunc, table string, row []Col) (*sqlTemplate, error) {
+	t := &sqlTemplate{pk: -1, cols: make([]colSig, len(row))}
+	for i, c := range row {
+		t.cols[i] = colSig{name: c.Name, flags: colFlags(c)}
+		if t.pk < 0 && c.isPrimaryKey() {
+			t.pk = i
+		}
+	}
+	if t.pk < 0 && op != insertOp {
+		return nil, ErrNoPrimaryKey
+	}
+	phFunc := phMaker()
+	var buf bytes.Buffer
+	switch op {
+	case getOp:
+		buf.WriteString("SELECT ")
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(v.Name)
+		}
+		buf.WriteString(" FROM ")
+		buf.WriteString(table)
+		buf.WriteString(" WHERE ")
+		buf.WriteString(row[t.pk].Name)
+		buf.WriteString("=")
+		buf.WriteString(phFunc())
+		t.args = []int{t.pk}
+	case insertOp:
+		buf.WriteString("INSERT INTO ")
+		buf.WriteString(table)
+		buf.WriteString("(")
+		for i, v := range row {
+			if v.skipOnInsert() {
+				continue
+			}
+			if len(t.args) > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(v.Name)
+			t.args = append(t.args, i)
+		}
+		buf.WriteString(")  VALUES (")
+		for i := range t.args {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(phFunc())
+		}
+		buf.WriteString(")")
+		if dbType == postgres && t.pk >= 0 {
+			//postgres inserts should use returning
+			t.returning = buf.String() + " RETURNING " + row[t.pk].Name
+		}
+	case updateOp:
+		buf.WriteString("UPDATE ")
+		buf.WriteString(table)
+		buf.WriteString(" SET ")
+		for i, v := range row {
+			if v.isPrimaryKey() {
+				continue
+			}
+			if len(t.args) > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(v.Name)
+			buf.WriteString("=")
+			buf.WriteString(phFunc())
+			t.args = append(t.args, i)
+		}
+		buf.WriteString(" WHERE ")
+		buf.WriteString(row[t.pk].Name)
+		buf.WriteString("=")
+		buf.WriteString(phFunc())
+		t.args = append(t.args, t.pk)
+	case deleteOp:
+		buf.WriteString("DELETE FROM ")
+		buf.WriteString(table)
+		buf.WriteString(" WHERE ")
+		buf.WriteString(row[t.pk].Name)
+		buf.WriteString("=")
+		buf.WriteString(phFunc())
+		t.args = []int{t.pk}
+	}
+	t.query = buf.String()
+	t.names = make([]string, len(t.args))
+	for i, idx := range t.args {
+		t.names[i] = row[idx].Name
+	}
+	return t, nil
+}
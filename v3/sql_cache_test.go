@@ -0,0 +1,136 @@
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+)
+
+//flexRow is a model whose columns can change between calls
+type flexRow struct {
+	cols []Col
+}
+
+func (f *flexRow) DBName() string { return "flex" }
+
+func (f *flexRow) DBRow() []Col { return f.cols }
+
+func (f *flexRow) DBScan(scanner Scanner) error { return nil }
+
+func TestSQLFor(t *testing.T) {
+	cp := &Company{ID: 7, Name: "IBM", Ticker: "IBM"}
+	pk := &ColOpt{Flags: PrimaryKey | NoInsert}
+	row := []Col{NewCol("ID", cp.ID, pk), NewCol("Name", cp.Name, nil), NewCol("Ticker", cp.Ticker, nil)}
+	tests := []struct {
+		op       sqlOp
+		dbType   dbTyp
+		phMaker  func() placeHolderFunc
+		expected string
+		args     []interface{}
+	}{
+		{getOp, sqlite, defaultPlaceHolder, "SELECT ID,Name,Ticker FROM company WHERE ID=?", []interface{}{int64(7)}},
+		{insertOp, sqlite, defaultPlaceHolder, "INSERT INTO company(Name,Ticker)  VALUES (?,?)", []interface{}{"IBM", "IBM"}},
+		{insertOp, postgres, pgPlaceHolder, "INSERT INTO company(Name,Ticker)  VALUES ($1,$2)", []interface{}{"IBM", "IBM"}},
+		{updateOp, postgres, pgPlaceHolder, "UPDATE company SET Name=$1,Ticker=$2 WHERE ID=$3", []interface{}{"IBM", "IBM", int64(7)}},
+		{deleteOp, mysql, defaultPlaceHolder, "DELETE FROM company WHERE ID=?", []interface{}{int64(7)}},
+	}
+	for _, tc := range tests {
+		tpl, err := sqlFor(tc.op, tc.dbType, tc.phMaker, cp, row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tpl.query != tc.expected {
+			t.Errorf("expected %q got %q", tc.expected, tpl.query)
+		}
+		args := tpl.values(row)
+		if len(args) != len(tc.args) {
+			t.Fatalf("expected args %v got %v", tc.args, args)
+		}
+		for i := range args {
+			if args[i] != tc.args[i] {
+				t.Errorf("expected args %v got %v", tc.args, args)
+			}
+		}
+		again, _ := sqlFor(tc.op, tc.dbType, tc.phMaker, cp, row)
+		if again != tpl {
+			t.Errorf("expected cached template for %q", tc.expected)
+		}
+	}
+	tpl, _ := sqlFor(insertOp, postgres, pgPlaceHolder, cp, row)
+	if tpl.returning != "INSERT INTO company(Name,Ticker)  VALUES ($1,$2) RETURNING ID" {
+		t.Errorf("unexpected returning query %q", tpl.returning)
+	}
+}
+
+func TestSQLForChangingColumns(t *testing.T) {
+	pk := &ColOpt{Flags: PrimaryKey}
+	f := &flexRow{cols: []Col{NewCol("id", 1, pk), NewCol("a", "x", nil)}}
+	tpl, err := sqlFor(updateOp, sqlite, defaultPlaceHolder, f, f.DBRow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.query != "UPDATE flex SET a=? WHERE id=?" {
+		t.Fatalf("unexpected query %q", tpl.query)
+	}
+	f.cols = append(f.cols, NewCol("b", "y", nil))
+	tpl, _ = sqlFor(updateOp, sqlite, defaultPlaceHolder, f, f.DBRow())
+	if tpl.query != "UPDATE flex SET a=?,b=? WHERE id=?" {
+		t.Fatalf("unexpected query %q", tpl.query)
+	}
+	f.cols[0].Opt = nil
+	if _, err := sqlFor(updateOp, sqlite, defaultPlaceHolder, f, f.DBRow()); err != ErrNoPrimaryKey {
+		t.Fatalf("expected ErrNoPrimaryKey got %v", err)
+	}
+}
+
+//execConn is a connection which only counts executed statements
+type execConn struct {
+	connection
+	execs int
+}
+
+func (c *execConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	c.execs++
+	return driver.RowsAffected(1), nil
+}
+
+func benchmarkSQL(b *testing.B, op sqlOp, cached bool) {
+	cp := &Company{ID: 7, Name: "IBM", Ticker: "IBM"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		row := cp.DBRow()
+		var (
+			tpl *sqlTemplate
+			err error
+		)
+		if cached {
+			tpl, err = sqlFor(op, postgres, pgPlaceHolder, cp, row)
+		} else {
+			tpl, err = buildSQL(op, postgres, pgPlaceHolder, cp.DBName(), row)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = tpl.values(row)
+	}
+}
+
+func BenchmarkGetSQLBuild(b *testing.B)     { benchmarkSQL(b, getOp, false) }
+func BenchmarkGetSQLCached(b *testing.B)    { benchmarkSQL(b, getOp, true) }
+func BenchmarkInsertSQLBuild(b *testing.B)  { benchmarkSQL(b, insertOp, false) }
+func BenchmarkInsertSQLCached(b *testing.B) { benchmarkSQL(b, insertOp, true) }
+func BenchmarkUpdateSQLBuild(b *testing.B)  { benchmarkSQL(b, updateOp, false) }
+func BenchmarkUpdateSQLCached(b *testing.B) { benchmarkSQL(b, updateOp, true) }
+
+func BenchmarkUpdate(b *testing.B) {
+	conn := &execConn{}
+	qc := &StmtContext{context: context.Background()}
+	cp := &Company{ID: 7, Name: "IBM", Ticker: "IBM"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := update(conn, qc, postgres, pgPlaceHolder, nil, cp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
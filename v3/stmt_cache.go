@@ -0,0 +1,268 @@
+package dbi
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+//StmtCacheStats are metrics of the prepared statement cache
+type StmtCacheStats struct {
+	Size      int    // number of statements currently cached
+	Hits      uint64 // number of times a cached statement was reused
+	Misses    uint64 // number of times a statement had to be prepared
+	Evictions uint64 // number of statements closed to stay within the limit
+}
+
+//stmtCache is LRU cache of prepared statements keyed by SQL text
+type stmtCache struct {
+	mu    sync.Mutex
+	conn  *sql.DB
+	limit int
+	lru   *list.List // of *stmtEntry, most recently used first
+	items map[string]*list.Element
+	stats StmtCacheStats
+}
+
+//stmtEntry is a cached statement, it is closed once it is evicted and no longer in use
+type stmtEntry struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int  // number of callers between acquire and release
+	evicted bool // removed from the cache, close on last release
+}
+
+func newStmtCache(conn *sql.DB, limit int) *stmtCache {
+	return &stmtCache{
+		conn:  conn,
+		limit: limit,
+		lru:   list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+//acquire returns cached statement for query preparing it on first use.
+//The statement is not closed before release is called even when it is evicted meanwhile,
+//callers release it once the statement started executing since database/sql keeps it open for its rows.
+func (sc *stmtCache) acquire(ctx context.Context, query string) (*stmtEntry, error) {
+	if entry := sc.cached(query); entry != nil {
+		return entry, nil
+	}
+
+	//prepare without holding the lock
+	stmt, err := sc.conn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if el, ok := sc.items[query]; ok {
+		//somebody else was faster
+		_ = stmt.Close()
+		sc.lru.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	sc.items[query] = sc.lru.PushFront(entry)
+	for sc.lru.Len() > sc.limit {
+		el := sc.lru.Back()
+		evicted := sc.lru.Remove(el).(*stmtEntry)
+		delete(sc.items, evicted.query)
+		sc.stats.Evictions++
+		sc.evict(evicted)
+	}
+	return entry, nil
+}
+
+//cached returns statement for query acquired as by acquire if it is cached, otherwise nil counting a miss
+func (sc *stmtCache) cached(query string) *stmtEntry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	el, ok := sc.items[query]
+	if !ok {
+		sc.stats.Misses++
+		return nil
+	}
+	sc.lru.MoveToFront(el)
+	sc.stats.Hits++
+	entry := el.Value.(*stmtEntry)
+	entry.refs++
+	return entry
+}
+
+//evict closes entry removed from the cache unless it is in use, sc.mu must be held
+func (sc *stmtCache) evict(entry *stmtEntry) {
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+//release ends the use of entry returned by acquire
+func (sc *stmtCache) release(entry *stmtEntry) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	entry.refs--
+	if entry.refs == 0 && entry.evicted {
+		_ = entry.stmt.Close()
+	}
+}
+
+//Stats returns the current metrics
+func (sc *stmtCache) Stats() StmtCacheStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	stats := sc.stats
+	stats.Size = sc.lru.Len()
+	return stats
+}
+
+//close closes all cached statements, the ones in use are closed once released
+func (sc *stmtCache) close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var firstErr error
+	for el := sc.lru.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*stmtEntry)
+		entry.evicted = true
+		if entry.refs > 0 {
+			continue
+		}
+		if err := entry.stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	sc.lru.Init()
+	sc.items = make(map[string]*list.Element)
+	return firstErr
+}
+
+//cachedConn is a connection executing all statements as cached prepared statements
+type cachedConn struct {
+	cache *stmtCache
+	tx    *sql.Tx
+	//statements of this transaction created via Tx.StmtContext
+	//they are closed by database/sql when the transaction ends
+	mu      sync.Mutex
+	txStmts map[string]*sql.Stmt
+}
+
+//stmt returns statement for query and function to be called once the statement started executing
+func (cc *cachedConn) stmt(ctx context.Context, query string) (*sql.Stmt, func(), error) {
+	if cc.tx == nil {
+		entry, err := cc.cache.acquire(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		return entry.stmt, func() { cc.cache.release(entry) }, nil
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if stmt, ok := cc.txStmts[query]; ok {
+		return stmt, func() {}, nil
+	}
+	//statements not cached yet are prepared on the connection of the transaction
+	//as preparing them via the pool could wait for the very connection the transaction holds
+	entry := cc.cache.cached(query)
+	if entry == nil {
+		stmt, err := cc.tx.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		cc.txStmts[query] = stmt
+		return stmt, func() {}, nil
+	}
+	//the transaction statement keeps its parent open until the transaction ends
+	stmt := cc.tx.StmtContext(ctx, entry.stmt)
+	cc.cache.release(entry)
+	cc.txStmts[query] = stmt
+	return stmt, func() {}, nil
+}
+
+func (cc *cachedConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, release, err := cc.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.QueryContext(ctx, args...)
+}
+
+func (cc *cachedConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	stmt, release, err := cc.stmt(ctx, query)
+	if err != nil {
+		//sql.Row can not be created with an error outside of database/sql
+		//so let the connection report it
+		if cc.tx != nil {
+			return cc.tx.QueryRowContext(ctx, query, args...)
+		}
+		return cc.cache.conn.QueryRowContext(ctx, query, args...)
+	}
+	defer release()
+	return stmt.QueryRowContext(ctx, args...)
+}
+
+func (cc *cachedConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	stmt, release, err := cc.stmt(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return stmt.ExecContext(ctx, args...)
+}
+
+//StmtCache is an optional configuration option which enables caching of prepared statements.
+//Statements generated by dbi are prepared on first use and reused afterwards,
+//at most limit statements are kept open, the least recently used ones are closed first.
+//Transactions reuse statements already cached, others are prepared on the transaction and closed when it ends.
+//Use Close to close the cached statements together with the handle.
+//db, err := New(mySqlConn, Postgres(), StmtCache(100))
+func StmtCache(limit int) DBOption {
+	return func(db *H) error {
+		if limit <= 0 {
+			return errors.New("statement cache limit must be positive")
+		}
+		db.stmts = newStmtCache(db.conn, limit)
+		return nil
+	}
+}
+
+//StmtCacheStats returns metrics of the prepared statement cache, zero when the cache is not enabled
+func (db *H) StmtCacheStats() StmtCacheStats {
+	if db.stmts == nil {
+		return StmtCacheStats{}
+	}
+	return db.stmts.Stats()
+}
+
+//Close closes the cached prepared statements (if any) and the underlying sql.DB
+func (db *H) Close() error {
+	if db.stmts != nil {
+		if err := db.stmts.close(); err != nil {
+			_ = db.conn.Close()
+			return err
+		}
+	}
+	return db.conn.Close()
+}
+
+//connection returns what statements of this handle should be executed on
+func (db *H) connection() connection {
+	if db.stmts == nil {
+		return db.conn
+	}
+	return &cachedConn{cache: db.stmts}
+}
+
+//connection returns what statements of this transaction should be executed on
+func (tx *Tx) connection() connection {
+	if tx.stmts == nil {
+		return tx.tx
+	}
+	return tx.stmts
+}
@@ -0,0 +1,93 @@
+package dbi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStmtCacheConcurrentEviction(t *testing.T) {
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "stmt_cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a single cached statement makes every other query evict the one just handed out
+	db, err := New(conn, StmtCache(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			//odd goroutines share the transaction
+			conn := db.connection()
+			if i%2 == 1 {
+				conn = tx.connection()
+			}
+			for j := 0; j < 200; j++ {
+				var n int
+				query := fmt.Sprintf("SELECT %d", (i+j)%50)
+				if err := conn.QueryRowContext(context.Background(), query).Scan(&n); err != nil {
+					errs <- err
+					return
+				}
+				if n != (i+j)%50 {
+					errs <- fmt.Errorf("%s returned %d", query, n)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	stats := db.StmtCacheStats()
+	if stats.Size != 1 || stats.Evictions == 0 {
+		t.Errorf("expected evictions with a single cached statement got %+v", stats)
+	}
+}
+
+func TestStmtCacheEvictInUse(t *testing.T) {
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "stmt_cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sc := newStmtCache(conn, 1)
+	ctx := context.Background()
+	one, err := sc.acquire(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	two, err := sc.acquire(ctx, "SELECT 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.release(two)
+	if !one.evicted {
+		t.Fatal("expected SELECT 1 to be evicted")
+	}
+	//evicted statement stays open until released
+	var n int
+	if err := one.stmt.QueryRowContext(ctx).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("want 1 got %d %v", n, err)
+	}
+	sc.release(one)
+	if err := one.stmt.QueryRowContext(ctx).Scan(&n); err == nil {
+		t.Fatal("expected statement to be closed after release")
+	}
+}
@@ -0,0 +1,83 @@
+package dbi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//StmtError is returned by dbi operations when a statement fails in the database or driver,
+//it describes the operation and the failed statement. Use errors.Is and errors.As to inspect the cause.
+//Errors of dbi itself such as ErrNotFound or *ValidationError are returned as they are.
+type StmtError struct {
+	Operation string        // dbi operation e.g. Insert, Get, Select, Commit
+	Table     string        // table of the model, empty for arbitrary SQL such as Exec
+	SQL       string        // last statement of the operation, empty when it failed before executing any
+	Args      []interface{} // arguments of the statement with sensitive values masked
+	PK        interface{}   // primary key value of the model, nil when the operation has no single model or it is not set
+	TxID      uint64        // id of the transaction the operation ran in, 0 outside of transactions
+	Err       error         // cause of the failure
+}
+
+func (e *StmtError) Error() string {
+	var buf strings.Builder
+	buf.WriteString(e.Operation)
+	if e.Table != "" {
+		buf.WriteString(" ")
+		buf.WriteString(e.Table)
+	}
+	if e.PK != nil {
+		fmt.Fprintf(&buf, " (primary key %v)", e.PK)
+	}
+	buf.WriteString(": ")
+	buf.WriteString(e.Err.Error())
+	if e.SQL != "" {
+		buf.WriteString(" [")
+		buf.WriteString(e.SQL)
+		buf.WriteString("]")
+	}
+	return buf.String()
+}
+
+//Unwrap returns the cause of the failure
+func (e *StmtError) Unwrap() error {
+	return e.Err
+}
+
+//stmtInfo is the part of a statement StmtError is made of
+type stmtInfo struct {
+	model DBNamer
+	query string
+	args  []interface{}
+	names []string
+}
+
+//stmtError returns err of the operation of qc wrapped in StmtError when it is caused by a failed statement
+func (db *H) stmtError(qc *StmtContext, err error) error {
+	var se *StmtError
+	if err == nil || qc.failed == nil || !errors.Is(err, qc.failed) || errors.As(err, &se) {
+		return err
+	}
+	//results of a successful statement dbi reports as errors
+	if err == ErrNotFound || err == ErrMultipleRows {
+		return err
+	}
+	se = &StmtError{
+		Operation: qc.operation,
+		Table:     traceTable(qc, qc.target),
+		SQL:       qc.last.query,
+		TxID:      qc.txID,
+		Err:       err,
+	}
+	se.Args, _ = redactArgs(db.redact, qc.last.model, qc.last.names, qc.last.args)
+	if m, ok := qc.target.(DBRowMarshaler); ok {
+		if pk := getPKFromColumns(m.DBRow()); pk != nil && pk.Val != nil && !reflect.ValueOf(pk.Val).IsZero() {
+			se.PK = pk.Val
+			if pk.isSensitive() {
+				se.PK = RedactedValue
+			}
+		}
+	}
+	return se
+}
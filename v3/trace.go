@@ -0,0 +1,37 @@
+package dbi
+
+import (
+	"context"
+	"errors"
+)
+
+//TraceInfo describes a dbi operation passed to Tracer
+type TraceInfo struct {
+	Operation string // dbi operation e.g. CreateTable, Insert, Get, Select, Begin, Commit
+	Table     string // table of the model, empty for arbitrary SQL such as Exec
+	TxID      uint64 // id of the transaction the operation runs in, 0 outside of transactions
+	//TxContext is the context returned by Tracer.Start for Begin of the transaction the operation runs in,
+	//nil outside of transactions. It allows spans of statements to be linked to the span of their transaction.
+	TxContext context.Context
+}
+
+//Tracer is notified when dbi operations start and end so that they can be traced
+//without dbi depending on any tracing library.
+//Start returns the context the operation is executed with e.g. ctx with a new span,
+//End receives that context and the error of the operation if any.
+type Tracer interface {
+	Start(ctx context.Context, info *TraceInfo) context.Context
+	End(ctx context.Context, info *TraceInfo, err error)
+}
+
+//Tracing is an optional configuration option which reports every dbi operation to t
+//db, err := New(mySqlConn, Tracing(myTracer))
+func Tracing(t Tracer) DBOption {
+	return func(db *H) error {
+		if t == nil {
+			return errors.New("tracer is nil")
+		}
+		db.tracer = t
+		return nil
+	}
+}
@@ -1,58 +1,128 @@
 package dbi
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 )
 
 //TxContext stores options for transactions
-type TxContext struct{}
+type TxContext struct {
+	context context.Context
+}
 
 //TxOption configures a transaction
 type TxOption func(*TxContext) error
 
+//WithTxContext returns TxOption starting the transaction with ctx, the transaction is rolled back when ctx is done.
+//The context is also passed to Tracer and QueryLogger for Begin, Commit and Rollback.
+func WithTxContext(ctx context.Context) TxOption {
+	return func(tc *TxContext) error {
+		tc.context = ctx
+		return nil
+	}
+}
+
 //Begin starts a transaction
 func (db *H) Begin(opts ...TxOption) (*Tx, error) {
-	fmt.Fprintf(db.lw, "BEGIN\n")
-	sqlTx, err := db.DB().Begin()
+	tc := TxContext{}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		if err := opt(&tc); err != nil {
+			return nil, err
+		}
+	}
+	if tc.context == nil {
+		tc.context = context.Background()
+	}
+	qc := StmtContext{context: tc.context, txID: nextTxID()}
+	db.startOp(&qc, "Begin", nil)
+	ql := db.queryLogger()
+	ev := newQueryEvent(ql, &qc, "Begin", nil, "BEGIN", nil, nil)
+	sqlTx, err := db.conn.BeginTx(qc.context, nil)
+	err = logQuery(ql, ev, err)
+	if err := db.endOp(&qc, err); err != nil {
+		return nil, err
+	}
+	tx, err := newTx(db, sqlTx, qc.txID)
 	if err != nil {
 		return nil, err
 	}
-	return newTx(db, sqlTx)
+	tx.ctx = tc.context
+	//statements of the transaction are linked to the Begin span
+	tx.traceCtx = qc.context
+	return tx, nil
 }
 
 //Tx is a reference to a specific transaction
 type Tx struct {
-	dbi *H
-	tx  *sql.Tx
+	dbi      *H
+	tx       *sql.Tx
+	id       uint64
+	ctx      context.Context // context given to Begin
+	traceCtx context.Context // context returned by Tracer.Start for Begin
+	stmts    *cachedConn
 }
 
-func newTx(db *H, tx *sql.Tx) (*Tx, error) {
-	return &Tx{
+func newTx(db *H, tx *sql.Tx, id uint64) (*Tx, error) {
+	t := &Tx{
 		dbi: db,
 		tx:  tx,
-	}, nil
+		id:  id,
+	}
+	if db.stmts != nil {
+		t.stmts = &cachedConn{cache: db.stmts, tx: tx, txStmts: make(map[string]*sql.Stmt)}
+	}
+	return t, nil
+}
+
+//ID returns the id identifying this transaction in QueryEvent.TxID
+func (tx *Tx) ID() uint64 {
+	return tx.id
+}
+
+//initStmContext is initStmContext marking the statement as executed in this transaction
+func (tx *Tx) initStmContext(qc *StmtContext, optionFunc StmtOption) error {
+	if err := initStmContext(qc, optionFunc); err != nil {
+		return err
+	}
+	qc.txID = tx.id
+	qc.txContext = tx.traceCtx
+	qc.txConn = tx.tx
+	return nil
+}
+
+//end commits or rolls back this transaction
+func (tx *Tx) end(op, query string, fn func() error) error {
+	qc := StmtContext{context: tx.ctx, txID: tx.id, txContext: tx.traceCtx}
+	tx.dbi.startOp(&qc, op, nil)
+	ql := tx.dbi.queryLogger()
+	ev := newQueryEvent(ql, &qc, op, nil, query, nil, nil)
+	err := fn()
+	err = logQuery(ql, ev, err)
+	return tx.dbi.endOp(&qc, err)
 }
 
 //Commit commits this transaction
 func (tx *Tx) Commit() error {
-	fmt.Fprintf(tx.dbi.lw, "COMMIT\n")
-	return tx.tx.Commit()
+	return tx.end("Commit", "COMMIT", tx.tx.Commit)
 }
 
 //Rollback aborts this transaction
 func (tx *Tx) Rollback() error {
-	fmt.Fprintf(tx.dbi.lw, "ROLLBACK\n")
-	return tx.tx.Rollback()
+	return tx.end("Rollback", "ROLLBACK", tx.tx.Rollback)
 }
 
 //Insert a record into sql and return a Col with the primary key and any error
 func (tx *Tx) Insert(s DBRowMarshaler, optionFunc StmtOption) (Col, error) {
 	qc := StmtContext{}
-	if err := initStmContext(&qc, optionFunc); err != nil {
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
 		return Col{}, err
 	}
-	return insert(tx.tx, &qc, tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.lw, s)
+	tx.dbi.startOp(&qc, "Insert", s)
+	col, err := insert(tx.connection(), &qc, tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.queryLogger(), s)
+	return col, tx.dbi.endOp(&qc, err)
 }
 
 //Select runs an SQL query and populates dst and returns an error if any.
@@ -68,18 +138,20 @@ func (tx *Tx) Select(
 	optionFunc StmtOption,
 	where string, args ...sql.NamedArg) error {
 	qc := StmtContext{}
-	if err := initStmContext(&qc, optionFunc); err != nil {
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return selectQuery(
-		tx.tx,
+	tx.dbi.startOp(&qc, "Select", dst)
+	err := selectQuery(
+		tx.connection(),
 		tx.dbi.placeholder,
-		tx.dbi.namedArgPrefix,
-		tx.dbi.lw,
+		tx.dbi.syntax,
+		tx.dbi.queryLogger(),
 		dst,
 		&qc,
 		where,
 		args...)
+	return tx.dbi.endOp(&qc, err)
 }
 
 //DBI returns the originating DBI handle for this transaction
@@ -90,26 +162,204 @@ func (tx *Tx) DBI() *H {
 //Get a record from SQL using the supplied PrimaryKey
 func (tx *Tx) Get(s DBRowUnmarshaler, optionFunc StmtOption) error {
 	qc := StmtContext{}
-	if err := initStmContext(&qc, optionFunc); err != nil {
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return get(tx.tx, &qc, tx.dbi.placeholder, tx.dbi.lw, s)
+	tx.dbi.startOp(&qc, "Get", s)
+	err := get(tx.connection(), &qc, tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.queryLogger(), s)
+	return tx.dbi.endOp(&qc, err)
 }
 
 //Update a record in SQL using the supplied data
 func (tx *Tx) Update(s DBRowUnmarshaler, optionFunc StmtOption) error {
 	qc := StmtContext{}
-	if err := initStmContext(&qc, optionFunc); err != nil {
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return update(tx.tx, &qc, tx.dbi.placeholder, tx.dbi.lw, s)
+	tx.dbi.startOp(&qc, "Update", s)
+	err := update(tx.connection(), &qc, tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.queryLogger(), s)
+	return tx.dbi.endOp(&qc, err)
 }
 
 //Delete deletes a single row from db using the given models PrimaryKey
 func (tx *Tx) Delete(s DBRowMarshaler, optionFunc StmtOption) error {
 	qc := StmtContext{}
-	if err := initStmContext(&qc, optionFunc); err != nil {
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	tx.dbi.startOp(&qc, "Delete", s)
+	err := deleteRow(tx.connection(), &qc, tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.queryLogger(), s)
+	return tx.dbi.endOp(&qc, err)
+}
+
+//DeleteWhere deletes all rows of the model's table matching where and returns the number of deleted rows.
+//The where clause supports the same named arguments as Select e.g. "WHERE expires < @now"
+func (tx *Tx) DeleteWhere(s DBNamer, optionFunc StmtOption, where string, args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	tx.dbi.startOp(&qc, "DeleteWhere", s)
+	n, err := deleteWhere(tx.connection(), &qc, tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), s, where, args...)
+	return n, tx.dbi.endOp(&qc, err)
+}
+
+//UpdateWhere sets the supplied columns on all rows of the model's table matching where
+//and returns the number of updated rows.
+//The where clause supports the same named arguments as Select e.g. "WHERE last_seen < @cutoff"
+func (tx *Tx) UpdateWhere(s DBNamer, optionFunc StmtOption, setCols []Col, where string, args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	tx.dbi.startOp(&qc, "UpdateWhere", s)
+	n, err := updateWhere(tx.connection(), &qc, tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), s, setCols, where, args...)
+	return n, tx.dbi.endOp(&qc, err)
+}
+
+//Exec executes an arbitrary SQL statement that uses named arguments e.g. "UPDATE person SET last = @last"
+//the named arguments are translated to placeholders of the configured database
+func (tx *Tx) Exec(optionFunc StmtOption, query string, args ...sql.NamedArg) (sql.Result, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return nil, err
+	}
+	tx.dbi.startOp(&qc, "Exec", nil)
+	res, err := execQuery(tx.connection(), &qc, tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), query, args...)
+	return res, tx.dbi.endOp(&qc, err)
+}
+
+//QueryRaw runs an arbitrary SQL query that uses named arguments and returns the resulting sql.Rows
+//it is the responsibility of the caller to close the returned rows
+func (tx *Tx) QueryRaw(optionFunc StmtOption, query string, args ...sql.NamedArg) (*sql.Rows, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return nil, err
+	}
+	tx.dbi.startOp(&qc, "QueryRaw", nil)
+	rows, err := rawQuery(tx.connection(), &qc, tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), query, args...)
+	return rows, tx.dbi.endOp(&qc, err)
+}
+
+//SelectSQL runs an arbitrary SQL query (joins, CTEs, subqueries...) and populates dst.
+//Unlike Select the query is used as is, only the named arguments are translated.
+//Each row is scanned using DBScan of the dst element type therefore the query
+//must return the same number of columns as DBRow() of that type, otherwise an error listing the mismatch is returned.
+func (tx *Tx) SelectSQL(
+	dst interface{},
+	optionFunc StmtOption,
+	query string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	tx.dbi.startOp(&qc, "SelectSQL", dst)
+	err := selectSQL(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), dst, &qc, query, args...)
+	return tx.dbi.endOp(&qc, err)
+}
+
+//SelectOne runs an SQL query like Select but scans the single resulting row into dst.
+//It returns ErrNotFound when no row matches and ErrMultipleRows when more than one does.
+func (tx *Tx) SelectOne(
+	dst DBRowUnmarshaler,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	tx.dbi.startOp(&qc, "SelectOne", dst)
+	err := selectOne(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), dst, &qc, where, args...)
+	return tx.dbi.endOp(&qc, err)
+}
+
+//Count returns the number of rows of the model's table matching where
+func (tx *Tx) Count(
+	s DBNamer,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	tx.dbi.startOp(&qc, "Count", s)
+	n, err := count(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), s, &qc, where, args...)
+	return n, tx.dbi.endOp(&qc, err)
+}
+
+//Exists reports whether any row of the model's table matches where
+func (tx *Tx) Exists(
+	s DBNamer,
+	optionFunc StmtOption,
+	where string,
+	args ...sql.NamedArg) (bool, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return false, err
+	}
+	tx.dbi.startOp(&qc, "Exists", s)
+	ok, err := exists(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), s, &qc, where, args...)
+	return ok, tx.dbi.endOp(&qc, err)
+}
+
+//SelectPage populates dst with a single page of at most size results using keyset pagination.
+//The results are ordered by sort, the primary key is added as the last sort column if not present
+//so that the order is stable. Sort columns must not contain NULL values.
+//The cursor is either empty for the first page or one of the cursors returned in Page.
+//The filter is an optional condition (without the WHERE keyword) e.g. "last = @last",
+//args are the named arguments of the filter just like in Select.
+func (tx *Tx) SelectPage(
+	dst interface{},
+	optionFunc StmtOption,
+	sort []Sort,
+	size int,
+	cursor string,
+	filter string,
+	args ...sql.NamedArg) (Page, error) {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return Page{}, err
+	}
+	tx.dbi.startOp(&qc, "SelectPage", dst)
+	page, err := selectPage(tx.connection(), tx.dbi.dbType, tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), dst, &qc, sort, size, cursor, filter, args...)
+	return page, tx.dbi.endOp(&qc, err)
+}
+
+//SelectJoin runs a query joining tables of several models and populates dst with one Tuple per row.
+//The column list is generated from DBRow() of each prototype with columns prefixed by DBName() (or alias given via JoinAs)
+//followed by from e.g. "FROM company JOIN annual_report ON annual_report.company_id = company.ID WHERE year = @year".
+//Each tuple holds new models of the same types as the prototypes, each scanned using its own DBScan
+//and only the span of columns belonging to it. Prototypes must be pointers.
+func (tx *Tx) SelectJoin(
+	dst *[]Tuple,
+	optionFunc StmtOption,
+	prototypes []DBRowUnmarshaler,
+	from string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
+		return err
+	}
+	tx.dbi.startOp(&qc, "SelectJoin", prototypes)
+	err := selectJoin(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), dst, &qc, prototypes, from, args...)
+	return tx.dbi.endOp(&qc, err)
+}
+
+//SelectValues runs an arbitrary SQL query e.g. "SELECT year, SUM(sales) AS sales FROM annual_report GROUP BY year"
+//and populates dst with plain Go values, see H.SelectValues for supported types of dst.
+func (tx *Tx) SelectValues(
+	dst interface{},
+	optionFunc StmtOption,
+	query string,
+	args ...sql.NamedArg) error {
+	qc := StmtContext{}
+	if err := tx.initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return delete(tx.tx, &qc, tx.dbi.placeholder, tx.dbi.lw, s)
+	tx.dbi.startOp(&qc, "SelectValues", dst)
+	err := selectValues(tx.connection(), tx.dbi.placeholder, tx.dbi.syntax, tx.dbi.queryLogger(), dst, &qc, query, args...)
+	return tx.dbi.endOp(&qc, err)
 }
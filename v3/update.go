@@ -2,54 +2,112 @@ package dbi
 
 import (
 	"bytes"
-	"fmt"
-	"io"
+	"database/sql"
+	"errors"
 )
 
+//ErrNoColumns is returned by UpdateWhere when there are no columns to set
+var ErrNoColumns = errors.New("No columns to update")
+
 //Update a record in SQL using the supplied data
 func (db *H) Update(s DBRowUnmarshaler, optionFunc StmtOption) error {
 	qc := StmtContext{}
 	if err := initStmContext(&qc, optionFunc); err != nil {
 		return err
 	}
-	return update(db.DB(), &qc, db.placeholder, db.lw, s)
+	db.startOp(&qc, "Update", s)
+	err := update(db.connection(), &qc, db.dbType, db.placeholder, db.queryLogger(), s)
+	return db.endOp(&qc, err)
 }
 
-func update(conn connection, qc *StmtContext, phMaker func() placeHolderFunc, lw io.Writer, s DBRowUnmarshaler) error {
-	phFunc := phMaker()
+func update(conn connection, qc *StmtContext, dbType dbTyp, phMaker func() placeHolderFunc, ql QueryLogger, s DBRowUnmarshaler) error {
+	if err := noPreload(qc); err != nil {
+		return err
+	}
+	if err := Validate(qc.context, s); err != nil {
+		return err
+	}
 	row := s.DBRow()
-	pkey := getPKFromColumns(row)
-	if pkey == nil {
-		return ErrNoPrimaryKey
+	t, err := sqlFor(updateOp, dbType, phMaker, s, row)
+	if err != nil {
+		return err
+	}
+	args := t.values(row)
+	ev := newQueryEvent(ql, qc, "Update", s, t.query, args, t.names)
+	res, err := conn.ExecContext(qc.context, t.query, args...)
+	err = logExec(ql, ev, res, err)
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+//UpdateWhere sets the supplied columns on all rows of the model's table matching where
+//and returns the number of updated rows.
+//The where clause supports the same named arguments as Select e.g. "WHERE last_seen < @cutoff"
+func (db *H) UpdateWhere(s DBNamer, optionFunc StmtOption, setCols []Col, where string, args ...sql.NamedArg) (int64, error) {
+	qc := StmtContext{}
+	if err := initStmContext(&qc, optionFunc); err != nil {
+		return 0, err
+	}
+	db.startOp(&qc, "UpdateWhere", s)
+	n, err := updateWhere(db.connection(), &qc, db.placeholder, db.syntax, db.queryLogger(), s, setCols, where, args...)
+	return n, db.endOp(&qc, err)
+}
+
+func updateWhere(
+	conn connection,
+	qc *StmtContext,
+	phMaker func() placeHolderFunc,
+	syntax argSyntax,
+	ql QueryLogger,
+	s DBNamer,
+	setCols []Col,
+	where string,
+	args ...sql.NamedArg) (int64, error) {
+	if err := noPreload(qc); err != nil {
+		return 0, err
+	}
+	if len(setCols) == 0 {
+		return 0, ErrNoColumns
 	}
-	args := make([]interface{}, 0, len(row))
+	//positional placeholders of where would not line up with the ones used by SET
+	if len(positionalArgs(args)) > 0 {
+		return 0, ErrUpdateWherePositional
+	}
+	phFunc := phMaker()
+	qargs := make([]interface{}, 0, len(setCols)+len(args))
+	names := make([]string, 0, len(setCols)+len(args))
 	var buf bytes.Buffer
 	buf.WriteString("UPDATE ")
 	buf.WriteString(s.DBName())
 	buf.WriteString(" SET ")
-	for _, v := range row {
-		if v.isPrimaryKey() {
-			continue
-		}
-		if len(args) > 0 {
+	for i, v := range setCols {
+		if i > 0 {
 			buf.WriteString(",")
 		}
 		buf.WriteString(v.Name)
 		buf.WriteString("=")
 		buf.WriteString(phFunc())
-		args = append(args, v.Val)
-	}
-	buf.WriteString(" WHERE ")
-	buf.WriteString(pkey.Name)
-	buf.WriteString("=")
-	buf.WriteString(phFunc())
-	args = append(args, pkey.Val)
-	fmt.Fprintln(lw, buf.String(), args)
-	res, err := conn.ExecContext(qc.context, buf.String(), args...)
-	if err == nil {
-		if n, err := res.RowsAffected(); err == nil && n == 0 {
-			return ErrNotFound
-		}
+		qargs = append(qargs, v.Val)
+		names = append(names, v.Name)
 	}
-	return err
+	buf.WriteString(" ")
+	//placeholders of the where clause continue after the ones used by SET
+	whereQuery, whereArgs, whereNames, err := translateQuery(syntax, phFunc, where, args, qc.argSource)
+	if err != nil {
+		return 0, err
+	}
+	buf.WriteString(whereQuery)
+	qargs = append(qargs, whereArgs...)
+	names = append(names, whereNames...)
+	ev := newQueryEvent(ql, qc, "UpdateWhere", s, buf.String(), qargs, names)
+	res, err := conn.ExecContext(qc.context, buf.String(), qargs...)
+	err = logExec(ql, ev, res, err)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
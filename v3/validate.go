@@ -0,0 +1,203 @@
+package dbi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+//Validator is an optional interface models can implement to check themselves
+//before Insert or Update builds any SQL
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+//Rule checks the value of a single Col and returns an error describing why it is not valid
+type Rule func(c Col) error
+
+//DBRuler is implemented by models which declare validation rules keyed by Col name
+type DBRuler interface {
+	DBRules() map[string][]Rule
+}
+
+//FieldError describes a single column that failed validation.
+//Col is empty when the error was returned by the model's Validate method.
+type FieldError struct {
+	Col string
+	Err error
+}
+
+func (fe FieldError) Error() string {
+	if fe.Col == "" {
+		return fe.Err.Error()
+	}
+	return fmt.Sprintf("%s: %s", fe.Col, fe.Err)
+}
+
+//ValidationError is returned by Insert and Update when the model fails validation
+//it lists every failed column
+type ValidationError struct {
+	Table  string
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("Validation of ")
+	buf.WriteString(ve.Table)
+	buf.WriteString(" failed: ")
+	for i, fe := range ve.Fields {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(fe.Error())
+	}
+	return buf.String()
+}
+
+//ErrRequired is returned by Required rule when the value is missing
+var ErrRequired = errors.New("value is required")
+
+//Required returns a Rule that fails on nil values, empty strings and empty slices
+func Required() Rule {
+	return func(c Col) error {
+		if c.Val == nil {
+			return ErrRequired
+		}
+		rv := reflect.ValueOf(c.Val)
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if rv.IsNil() {
+				return ErrRequired
+			}
+		case reflect.String, reflect.Slice, reflect.Map:
+			if rv.Len() == 0 {
+				return ErrRequired
+			}
+		}
+		return nil
+	}
+}
+
+//MaxLen returns a Rule that fails when a string is longer than n characters
+//or a []byte is longer than n bytes
+func MaxLen(n int) Rule {
+	return func(c Col) error {
+		var l int
+		switch v := c.Val.(type) {
+		case string:
+			l = utf8.RuneCountInString(v)
+		case []byte:
+			l = len(v)
+		default:
+			return nil
+		}
+		if l > n {
+			return fmt.Errorf("length %d exceeds maximum of %d", l, n)
+		}
+		return nil
+	}
+}
+
+//Match returns a Rule that fails when a string value does not match re
+func Match(re *regexp.Regexp) Rule {
+	return func(c Col) error {
+		var ok bool
+		switch v := c.Val.(type) {
+		case string:
+			ok = re.MatchString(v)
+		case []byte:
+			ok = re.Match(v)
+		default:
+			return fmt.Errorf("expected string value but got %T", c.Val)
+		}
+		if !ok {
+			return fmt.Errorf("value does not match %s", re)
+		}
+		return nil
+	}
+}
+
+//Range returns a Rule that fails when a numeric value is outside of [min,max]
+func Range(min, max float64) Rule {
+	return func(c Col) error {
+		var f float64
+		rv := reflect.ValueOf(c.Val)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		default:
+			return fmt.Errorf("expected numeric value but got %T", c.Val)
+		}
+		if f < min || f > max {
+			return fmt.Errorf("value %v is outside of range [%v,%v]", c.Val, min, max)
+		}
+		return nil
+	}
+}
+
+var varcharRegexp = regexp.MustCompile(`(?i)^\s*(?:n?varchar|n?char|character varying|character)\s*\(\s*(\d+)\s*\)`)
+
+//declaredMaxLen returns the size of varchar(N) like type declared in ColOpt.Type
+func declaredMaxLen(c Col) (int, bool) {
+	if c.Opt == nil || c.Opt.Type == "" {
+		return 0, false
+	}
+	m := varcharRegexp.FindStringSubmatch(c.Opt.Type)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+//Validate runs the column rules and Validator of the model s as Insert and Update do before writing it,
+//columns declared as varchar(N) in ColOpt.Type are always checked for length
+func Validate(ctx context.Context, s DBRowMarshaler) error {
+	var (
+		rules  map[string][]Rule
+		fields []FieldError
+	)
+	if r, ok := s.(DBRuler); ok {
+		rules = r.DBRules()
+	}
+	for _, c := range s.DBRow() {
+		if n, ok := declaredMaxLen(c); ok {
+			if err := MaxLen(n)(c); err != nil {
+				fields = append(fields, FieldError{Col: c.Name, Err: err})
+				continue
+			}
+		}
+		for _, rule := range rules[c.Name] {
+			if err := rule(c); err != nil {
+				fields = append(fields, FieldError{Col: c.Name, Err: err})
+				break
+			}
+		}
+	}
+	if v, ok := s.(Validator); ok {
+		if err := v.Validate(ctx); err != nil {
+			if ve, ok := err.(*ValidationError); ok {
+				fields = append(fields, ve.Fields...)
+			} else {
+				fields = append(fields, FieldError{Err: err})
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return &ValidationError{Table: s.DBName(), Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package dbi
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		s      *Subscriber
+		failed []string
+	}{
+		{&Subscriber{Email: "john@example.com", Name: "John", Age: 30}, nil},
+		{&Subscriber{Email: "", Name: "John", Age: 30}, []string{"email"}},
+		{&Subscriber{Email: "john", Name: "Johnathan Doe", Age: 30}, []string{"email", "name"}},
+		{&Subscriber{Email: "john@example.com", Name: "Jöhn Dœ", Age: 200}, []string{"age"}},
+		{&Subscriber{Email: "root@example.com", Name: "root", Age: 30}, []string{""}},
+	}
+	for _, test := range tests {
+		err := Validate(context.Background(), test.s)
+		if test.failed == nil {
+			if err != nil {
+				t.Errorf("expected no error but got %s", err)
+			}
+			continue
+		}
+		ve, ok := err.(*ValidationError)
+		if !ok {
+			t.Fatalf("expected *ValidationError but got %v", err)
+		}
+		if ve.Table != "subscriber" {
+			t.Errorf("expected table subscriber but got %s", ve.Table)
+		}
+		if len(ve.Fields) != len(test.failed) {
+			t.Fatalf("expected %v to fail but got %s", test.failed, ve)
+		}
+		for i, col := range test.failed {
+			if ve.Fields[i].Col != col {
+				t.Errorf("expected %s to fail but got %s", col, ve.Fields[i])
+			}
+		}
+	}
+}
+
+func TestDeclaredMaxLen(t *testing.T) {
+	var tests = []struct {
+		typ string
+		val interface{}
+		n   int
+		ok  bool
+	}{
+		{"varchar(255)", "", 255, true},
+		{"VARCHAR (20) NOT NULL", "", 20, true},
+		{"character varying(12)", "", 12, true},
+		{"char(2)", "", 2, true},
+		{"text", "", 0, false},
+		{"", strings.Repeat("x", 300), 0, false},
+		{"", make([]byte, 1000), 0, false},
+	}
+	for _, test := range tests {
+		n, ok := declaredMaxLen(Col{Val: test.val, Opt: &ColOpt{Type: test.typ}})
+		if n != test.n || ok != test.ok {
+			t.Errorf("%q: expected %d,%v but got %d,%v", test.typ, test.n, test.ok, n, ok)
+		}
+	}
+}